@@ -1,9 +1,13 @@
 package config
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"regexp"
+	"strings"
 
+	"github.com/nariahlamb/sharesubweb/filter"
 	"gopkg.in/yaml.v3"
 )
 
@@ -16,6 +20,7 @@ type Config struct {
 	Output        OutputConfig        `yaml:"output"`
 	Tasks         []TaskConfig        `yaml:"tasks"`   // 计划任务配置
 	WebHooks      []WebHookConfig     `yaml:"webhooks"` // WebHook配置
+	Profiles      []ProfileConfig     `yaml:"profiles"` // 命名输出配置
 }
 
 // AppConfig 应用基本配置
@@ -50,27 +55,61 @@ type APIConfig struct {
 	RetryCount int          `yaml:"retry-count"` // 重试次数
 	OpenAIKey string        `yaml:"openai-key"`  // OpenAI API密钥
 	GeminiKey string        `yaml:"gemini-key"`  // Gemini API密钥
+	PaidProbe bool          `yaml:"paid-probe"`  // 是否使用密钥发起真实请求检测，会产生API费用
 	List      []APIItemConfig `yaml:"list"`      // 待检测API列表
 }
 
 // APIItemConfig API项配置
 type APIItemConfig struct {
-	Name    string            `yaml:"name"`    // API名称
-	Enable  bool              `yaml:"enable"`  // 是否启用该API检测
-	URL     string            `yaml:"url"`     // API URL
-	Headers map[string]string `yaml:"headers"` // API请求头
+	Name       string            `yaml:"name"`        // API名称
+	Enable     bool              `yaml:"enable"`      // 是否启用该API检测
+	URL        string            `yaml:"url"`         // API URL
+	Headers    map[string]string `yaml:"headers"`     // API请求头
+	Tag        string            `yaml:"tag"`         // 重命名时使用的短标签，为空则使用检测器默认标签
+	Method     string            `yaml:"method"`      // 请求方法，默认GET
+	Timeout    int               `yaml:"timeout"`     // 请求超时（秒），为空则使用api.timeout
+	RetryCount int               `yaml:"retry-count"` // 尝试次数，为空则使用api.retry-count
+	Rules      []APIMatchRule    `yaml:"rules"`       // 自定义匹配规则，配置后覆盖内置检测器
+}
+
+// APIMatchRule API匹配规则，规则内所有条件均满足才算命中，按顺序取第一个命中的规则
+type APIMatchRule struct {
+	Status    []int             `yaml:"status"`     // 期望的HTTP状态码，为空则不限制
+	BodyRegex string            `yaml:"body-regex"` // 响应体需匹配的正则
+	Header    map[string]string `yaml:"header"`     // 响应头名称 -> 需匹配的正则
+	Redirect  string            `yaml:"redirect"`   // 重定向目标需匹配的正则，配置后不再自动跟随重定向
+	Result    string            `yaml:"result"`     // 命中后的结果：unlocked, blocked, region-only, originals-only，默认unlocked
 }
 
 // IPQualityConfig IP质量测试配置
 type IPQualityConfig struct {
-	Enable  bool `yaml:"enable"`
-	Timeout int  `yaml:"timeout"`
+	Enable      bool     `yaml:"enable"`
+	Timeout     int      `yaml:"timeout"`
+	Providers   []string `yaml:"providers"`    // 查询顺序：mmdb, ip2region, ipinfo, ip-api, ip.sb，前者失败或字段缺失时依次使用后者
+	MMDBCity    string   `yaml:"mmdb-city"`    // GeoLite2-City.mmdb 文件路径
+	MMDBASN     string   `yaml:"mmdb-asn"`     // GeoLite2-ASN.mmdb 文件路径
+	IP2Region   string   `yaml:"ip2region"`    // ip2region.xdb 文件路径
+	IPInfoToken string   `yaml:"ipinfo-token"` // ipinfo.io令牌，可选
+	CacheTTL    int      `yaml:"cache-ttl"`    // 查询结果缓存时间（分钟），默认360
+	BanLists    []string `yaml:"ban-lists"`    // 封禁CIDR列表文件，默认 ./ip/ban.txt 与 ./ip/banv6.txt
+	UnbanLists  []string `yaml:"unban-lists"`  // 解封CIDR列表文件，优先于封禁列表
+	DNSBL       []string `yaml:"dnsbl"`        // DNSBL黑名单域，仅检测IPv4
+	ProxyDetect bool     `yaml:"proxy-detect"` // 是否通过ip-api查询代理/机房标记
 }
 
 // NodeProcessConfig 节点处理配置
 type NodeProcessConfig struct {
 	Rename RenameConfig `yaml:"rename"`
 	Filter FilterConfig `yaml:"filter"`
+	Select SelectConfig `yaml:"select"`
+}
+
+// SelectConfig 输出节点排序与数量限制配置
+type SelectConfig struct {
+	SortBy          []string `yaml:"sort-by"`          // 排序键：latency, speed, success_rate, country, name, subscription，前缀-表示反向
+	PerCountry      int      `yaml:"per-country"`      // 每个国家最多保留的节点数，0表示不限制
+	PerSubscription int      `yaml:"per-subscription"` // 每个订阅最多保留的节点数，0表示不限制
+	MaxNodes        int      `yaml:"max-nodes"`        // 最多输出的节点数，0表示不限制
 }
 
 // RenameConfig 节点重命名配置
@@ -79,6 +118,20 @@ type RenameConfig struct {
 	AddPrefix string `yaml:"add-prefix"`
 	AddSuffix string `yaml:"add-suffix"`
 	Template  string `yaml:"template"`
+	Language  string `yaml:"language"` // 国家名称显示语言：zh, en，默认zh
+	// 按顺序执行的重命名步骤，为空时依次执行 template、prefix、suffix
+	Pipeline     []RenameStep `yaml:"pipeline"`
+	Dedupe       bool         `yaml:"dedupe"`        // 是否为重名节点编号
+	DedupeFormat string       `yaml:"dedupe-format"` // 编号格式，默认 "%s %02d"
+}
+
+// RenameStep 重命名步骤
+type RenameStep struct {
+	Type    string `yaml:"type"`    // 步骤类型：replace, delete, prefix, suffix, template, normalize
+	Pattern string `yaml:"pattern"` // replace/delete使用的正则表达式
+	Replace string `yaml:"replace"` // replace的替换内容，支持$1引用分组
+	Value   string `yaml:"value"`   // prefix/suffix/template的内容，为空时使用add-prefix/add-suffix/template
+	Form    string `yaml:"form"`    // normalize的规范化形式：NFC, NFD, NFKC, NFKD，默认NFKC
 }
 
 // FilterConfig 节点过滤配置
@@ -86,18 +139,120 @@ type FilterConfig struct {
 	Enable          bool     `yaml:"enable"`
 	IncludeKeywords []string `yaml:"include-keywords"`
 	ExcludeKeywords []string `yaml:"exclude-keywords"`
+	MinIPScore      int      `yaml:"min-ip-score"` // 最低IP评分，0表示不限制
+	IPTypes         []string `yaml:"ip-types"`     // 允许的IP类型：hosting, residential, mobile, unknown，为空表示不限制
+	Expression      string   `yaml:"expression"`   // 过滤表达式，如 country in ("JP", "SG") && latency < 300
 }
 
 // OutputConfig 输出配置
 type OutputConfig struct {
-	LocalPath  string        `yaml:"local-path"`
-	Formats    []FormatConfig `yaml:"formats"`
+	LocalPath  string           `yaml:"local-path"`
+	Formats    []FormatConfig   `yaml:"formats"`
+	Versions   int              `yaml:"versions"`    // 保留的历史版本数，用于回滚，0表示不保留
+	SafetyGate SafetyGateConfig `yaml:"safety-gate"` // 发布前的安全检查
 	// Gist相关配置
-	GistSave   bool          `yaml:"gist-save"`    // 是否启用Gist保存
-	GistToken  string        `yaml:"gist-token"`   // GitHub Gist令牌
-	GistID     string        `yaml:"gist-id"`      // Gist ID，如果为空则创建新的
-	GistDesc   string        `yaml:"gist-desc"`    // Gist描述
-	GistFiles  []string      `yaml:"gist-files"`   // 要保存到Gist的文件名
+	GistSave  bool                `yaml:"gist-save"`  // 是否启用Gist保存
+	GistToken string              `yaml:"gist-token"` // GitHub Gist令牌
+	GistID    string              `yaml:"gist-id"`    // Gist ID，如果为空则创建新的
+	GistDesc  string              `yaml:"gist-desc"`  // Gist描述
+	GistFiles []string            `yaml:"gist-files"` // 要保存到Gist的文件名
+	Clash     ClashOutputConfig   `yaml:"clash"`      // Clash输出配置
+	SingBox   SingBoxOutputConfig `yaml:"singbox"`    // sing-box输出配置
+}
+
+// ClashOutputConfig Clash输出配置
+type ClashOutputConfig struct {
+	Template      string               `yaml:"template"`       // 基础模板文件路径，为空时使用内置模板
+	TestURL       string               `yaml:"test-url"`       // 自动测速代理组使用的测试地址
+	Interval      int                  `yaml:"interval"`       // 自动测速间隔（秒），默认300
+	RegionGroups  RegionGroupConfig    `yaml:"region-groups"`  // 按国家自动生成的代理组
+	ServiceGroups []ServiceGroupConfig `yaml:"service-groups"` // 按解锁结果生成的服务代理组
+	RuleProviders []RuleProviderConfig `yaml:"rule-providers"` // 规则集，按顺序生成RULE-SET规则
+	Rules         []string             `yaml:"rules"`          // 附加规则，位于规则集之后
+	Final         string               `yaml:"final"`          // 未匹配任何规则时使用的策略，默认DIRECT
+	ProxyProvider ProxyProviderConfig  `yaml:"proxy-provider"` // 通过代理集引用节点，不在配置中内联节点
+}
+
+// ProxyProviderConfig Clash代理集配置
+type ProxyProviderConfig struct {
+	Enable   bool   `yaml:"enable"`
+	Name     string `yaml:"name"`     // 代理集名称，默认nodes
+	URL      string `yaml:"url"`      // 代理集地址，即本服务的 /provider/:profile，{profile}与{token}替换为输出配置的名称与访问令牌
+	Interval int    `yaml:"interval"` // 客户端更新代理集的间隔（秒），默认3600
+}
+
+// RegionGroupConfig 按国家自动生成的代理组配置
+type RegionGroupConfig struct {
+	Enable    bool   `yaml:"enable"`
+	Type      string `yaml:"type"`      // 代理组类型：url-test, fallback, load-balance，默认url-test
+	MinNodes  int    `yaml:"min-nodes"` // 节点数少于该值的国家不单独建组，默认1
+	Tolerance int    `yaml:"tolerance"` // url-test的切换容差（毫秒）
+	Strategy  string `yaml:"strategy"`  // load-balance的策略：consistent-hashing, round-robin
+}
+
+// ServiceGroupConfig 按解锁结果生成的服务代理组配置
+type ServiceGroupConfig struct {
+	Name   string   `yaml:"name"`   // 代理组名称
+	Type   string   `yaml:"type"`   // 代理组类型：select, url-test, fallback, load-balance，默认url-test
+	APIs   []string `yaml:"apis"`   // 任一检测项可用的节点加入该组
+	Filter string   `yaml:"filter"` // 过滤表达式，与apis同时配置时需同时满足
+}
+
+// RuleProviderConfig 规则集配置
+type RuleProviderConfig struct {
+	Name      string `yaml:"name"`
+	Type      string `yaml:"type"`       // http：客户端下载；file：客户端本地文件；local：读取本机文件并内联到配置中
+	Behavior  string `yaml:"behavior"`   // domain, ipcidr, classical
+	Format    string `yaml:"format"`     // http/file规则集的文件格式：yaml, text，默认yaml
+	URL       string `yaml:"url"`        // http规则集地址
+	Path      string `yaml:"path"`       // 规则集文件路径
+	Interval  int    `yaml:"interval"`   // http规则集更新间隔（秒），默认86400
+	Policy    string `yaml:"policy"`     // 命中规则后使用的策略，默认Proxy
+	NoResolve bool   `yaml:"no-resolve"` // 是否为IP规则添加no-resolve
+}
+
+// SingBoxOutputConfig sing-box输出配置
+type SingBoxOutputConfig struct {
+	Version   string                 `yaml:"version"`    // 目标sing-box版本：1.8, 1.9, 1.10, 1.11, 1.12，默认1.11
+	MixedPort int                    `yaml:"mixed-port"` // 本地HTTP/SOCKS混合代理端口，默认7890
+	Tun       bool                   `yaml:"tun"`        // 是否添加TUN入站
+	TestURL   string                 `yaml:"test-url"`   // 自动测速出站使用的测试地址
+	Interval  int                    `yaml:"interval"`   // 自动测速间隔（秒），默认300
+	DNSRemote string                 `yaml:"dns-remote"` // 经代理查询的DNS服务器
+	DNSDirect string                 `yaml:"dns-direct"` // 直连查询的DNS服务器，用于解析节点域名
+	RuleSets  []SingBoxRuleSetConfig `yaml:"rule-sets"`  // 规则集，按顺序生成路由规则
+	Final     string                 `yaml:"final"`      // 未匹配任何规则时使用的出站：Proxy, Auto, direct, block，默认Proxy
+}
+
+// SingBoxRuleSetConfig sing-box规则集配置
+type SingBoxRuleSetConfig struct {
+	Tag      string `yaml:"tag"`
+	Type     string `yaml:"type"`     // remote：客户端下载；local：客户端本地文件
+	Format   string `yaml:"format"`   // binary, source，默认binary
+	URL      string `yaml:"url"`      // remote规则集地址
+	Path     string `yaml:"path"`     // local规则集路径
+	Outbound string `yaml:"outbound"` // 命中规则后使用的出站：Proxy, Auto, direct, block，默认Proxy
+}
+
+// SingBoxVersions 支持的sing-box目标版本
+var SingBoxVersions = []string{"1.8", "1.9", "1.10", "1.11", "1.12"}
+
+// ProfileConfig 命名输出配置，通过 /sub/:profile 访问，各配置使用独立的过滤、排序、重命名与格式
+type ProfileConfig struct {
+	Name    string       `yaml:"name"`    // 名称，用于URL与输出目录
+	Token   string       `yaml:"token"`   // 访问令牌，为空时不校验
+	Groups  []string     `yaml:"groups"`  // 只包含这些订阅（名称或ID）或分组的节点，为空表示全部
+	Filter  FilterConfig `yaml:"filter"`  // 过滤配置
+	Select  SelectConfig `yaml:"select"`  // 排序与数量限制
+	Rename  RenameConfig `yaml:"rename"`  // 重命名配置
+	Formats []string     `yaml:"formats"` // 输出格式：clash, singbox, v2ray, surge, quanx, loon, shadowrocket, stash，为空时使用output.formats中启用的格式
+}
+
+// SafetyGateConfig 发布前的安全检查配置，可用节点数异常时保留上次发布的文件
+type SafetyGateConfig struct {
+	Enable         bool `yaml:"enable"`
+	MinNodes       int  `yaml:"min-nodes"`        // 可用节点数少于该值时不发布，最小为1
+	MaxDropPercent int  `yaml:"max-drop-percent"` // 可用节点数比上次发布减少超过该百分比时不发布，0表示不检查
 }
 
 // FormatConfig 输出格式配置
@@ -141,9 +296,288 @@ func LoadConfig(file string) (*Config, error) {
 		return nil, err
 	}
 
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
 
+// Validate 检查配置中的表达式等内容是否有效
+func (c *Config) Validate() error {
+	if err := validateProcess(c.NodeProcess.Filter, c.NodeProcess.Select, c.NodeProcess.Rename); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool, len(c.Profiles))
+	for _, profile := range c.Profiles {
+		if !profileNamePattern.MatchString(profile.Name) {
+			return fmt.Errorf("输出配置名称无效: %q，只能包含字母、数字、-和_", profile.Name)
+		}
+		if names[profile.Name] {
+			return fmt.Errorf("输出配置名称重复: %s", profile.Name)
+		}
+		names[profile.Name] = true
+
+		if err := validateProcess(profile.Filter, profile.Select, profile.Rename); err != nil {
+			return fmt.Errorf("输出配置 %s: %v", profile.Name, err)
+		}
+		for _, format := range profile.Formats {
+			if !IsOutputFormat(format) {
+				return fmt.Errorf("输出配置 %s: 不支持的格式 %s", profile.Name, format)
+			}
+		}
+	}
+	if c.Output.Versions < 0 {
+		return fmt.Errorf("历史版本数不能为负数: %d", c.Output.Versions)
+	}
+	if gate := c.Output.SafetyGate; gate.MaxDropPercent < 0 || gate.MaxDropPercent > 100 {
+		return fmt.Errorf("安全检查的max-drop-percent应在0到100之间: %d", gate.MaxDropPercent)
+	}
+	if err := c.Output.Clash.validate(); err != nil {
+		return err
+	}
+	return c.Output.SingBox.validate()
+}
+
+// validate 检查Clash输出配置
+func (c *ClashOutputConfig) validate() error {
+	switch c.RegionGroups.Type {
+	case "", "url-test", "fallback", "load-balance":
+	default:
+		return fmt.Errorf("地区代理组类型无效: %s", c.RegionGroups.Type)
+	}
+
+	for _, group := range c.ServiceGroups {
+		if group.Name == "" {
+			return fmt.Errorf("服务代理组名称不能为空")
+		}
+		switch group.Type {
+		case "", "select", "url-test", "fallback", "load-balance":
+		default:
+			return fmt.Errorf("服务代理组 %s 的类型无效: %s", group.Name, group.Type)
+		}
+		if err := filter.Validate(group.Filter); err != nil {
+			return fmt.Errorf("服务代理组 %s 的过滤表达式无效: %v", group.Name, err)
+		}
+	}
+
+	for _, provider := range c.RuleProviders {
+		if provider.Name == "" {
+			return fmt.Errorf("规则集名称不能为空")
+		}
+		switch provider.Behavior {
+		case "domain", "ipcidr", "classical":
+		default:
+			return fmt.Errorf("规则集 %s 的behavior无效: %s", provider.Name, provider.Behavior)
+		}
+		switch provider.Type {
+		case "http":
+			if provider.URL == "" {
+				return fmt.Errorf("规则集 %s 缺少url", provider.Name)
+			}
+		case "file", "local":
+			if provider.Path == "" {
+				return fmt.Errorf("规则集 %s 缺少path", provider.Name)
+			}
+		default:
+			return fmt.Errorf("规则集 %s 的类型无效: %s", provider.Name, provider.Type)
+		}
+	}
+
+	if c.ProxyProvider.Enable && c.ProxyProvider.URL == "" {
+		return fmt.Errorf("启用代理集时必须配置url")
+	}
+	return nil
+}
+
+// IsSingBoxVersion 是否为支持的sing-box目标版本
+func IsSingBoxVersion(version string) bool {
+	for _, v := range SingBoxVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// validate 检查sing-box输出配置
+func (c *SingBoxOutputConfig) validate() error {
+	if c.Version != "" && !IsSingBoxVersion(c.Version) {
+		return fmt.Errorf("sing-box目标版本无效: %s，可选: %s", c.Version, strings.Join(SingBoxVersions, ", "))
+	}
+
+	tags := make(map[string]bool, len(c.RuleSets))
+	for _, ruleSet := range c.RuleSets {
+		if ruleSet.Tag == "" {
+			return fmt.Errorf("sing-box规则集tag不能为空")
+		}
+		if tags[ruleSet.Tag] {
+			return fmt.Errorf("sing-box规则集tag重复: %s", ruleSet.Tag)
+		}
+		tags[ruleSet.Tag] = true
+		switch ruleSet.Format {
+		case "", "binary", "source":
+		default:
+			return fmt.Errorf("sing-box规则集 %s 的format无效: %s", ruleSet.Tag, ruleSet.Format)
+		}
+		switch ruleSet.Type {
+		case "remote":
+			if ruleSet.URL == "" {
+				return fmt.Errorf("sing-box规则集 %s 缺少url", ruleSet.Tag)
+			}
+		case "local":
+			if ruleSet.Path == "" {
+				return fmt.Errorf("sing-box规则集 %s 缺少path", ruleSet.Tag)
+			}
+		default:
+			return fmt.Errorf("sing-box规则集 %s 的类型无效: %s", ruleSet.Tag, ruleSet.Type)
+		}
+		if !isSingBoxRouteOutbound(ruleSet.Outbound) {
+			return fmt.Errorf("sing-box规则集 %s 的outbound无效: %s，可选: Proxy, Auto, direct, block", ruleSet.Tag, ruleSet.Outbound)
+		}
+	}
+	if !isSingBoxRouteOutbound(c.Final) {
+		return fmt.Errorf("sing-box的final无效: %s，可选: Proxy, Auto, direct, block", c.Final)
+	}
+	return nil
+}
+
+// isSingBoxRouteOutbound 是否为sing-box路由可以使用的出站，区分大小写，为空时使用Proxy
+func isSingBoxRouteOutbound(outbound string) bool {
+	switch outbound {
+	case "", "Proxy", "Auto", "direct", "block":
+		return true
+	}
+	return false
+}
+
+// profileNamePattern 输出配置名称，同时用于URL路径与输出目录
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// IsOutputFormat 是否为支持的输出格式
+func IsOutputFormat(format string) bool {
+	switch format {
+	case "clash", "singbox", "v2ray", "surge", "quanx", "loon", "shadowrocket", "stash":
+		return true
+	}
+	return false
+}
+
+// validateProcess 检查过滤、排序与重命名配置
+func validateProcess(filterCfg FilterConfig, selectCfg SelectConfig, renameCfg RenameConfig) error {
+	if err := filter.Validate(filterCfg.Expression); err != nil {
+		return fmt.Errorf("过滤表达式无效: %v", err)
+	}
+
+	for _, key := range selectCfg.SortBy {
+		switch strings.TrimPrefix(key, "-") {
+		case "latency", "speed", "success_rate", "country", "name", "subscription":
+		default:
+			return fmt.Errorf("未知的排序键: %s", key)
+		}
+	}
+
+	for i, step := range renameCfg.Pipeline {
+		switch step.Type {
+		case "replace", "delete":
+			if _, err := regexp.Compile(step.Pattern); err != nil {
+				return fmt.Errorf("重命名步骤 %d 的正则表达式无效: %v", i+1, err)
+			}
+		case "normalize":
+			switch strings.ToUpper(step.Form) {
+			case "", "NFC", "NFD", "NFKC", "NFKD":
+			default:
+				return fmt.Errorf("重命名步骤 %d 的规范化形式无效: %s", i+1, step.Form)
+			}
+		case "prefix", "suffix", "template":
+		default:
+			return fmt.Errorf("重命名步骤 %d 的类型无效: %s", i+1, step.Type)
+		}
+	}
+	return ValidateDedupeFormat(renameCfg.DedupeFormat)
+}
+
+// ValidateDedupeFormat 检查重名编号格式，必须依次包含一个%s与一个整数占位符，如 "%s %02d"，为空时使用默认格式
+func ValidateDedupeFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+
+	var verbs []byte
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		if i < len(format) && format[i] == '%' {
+			continue
+		}
+		// 跳过标志、宽度与精度
+		for i < len(format) && strings.IndexByte("+-# 0123456789.", format[i]) >= 0 {
+			i++
+		}
+		if i == len(format) {
+			return fmt.Errorf("重名编号格式不完整: %q", format)
+		}
+		verbs = append(verbs, format[i])
+	}
+
+	if len(verbs) != 2 || verbs[0] != 's' || strings.IndexByte("dboxX", verbs[1]) < 0 {
+		return fmt.Errorf("重名编号格式无效: %q，必须依次包含一个%%s与一个整数占位符，如 \"%%s %%02d\"", format)
+	}
+	return nil
+}
+
+// DefaultProfileName 未配置输出配置时使用的默认名称
+const DefaultProfileName = "default"
+
+// GetProfiles 获取输出配置列表，未配置时根据全局的node-process与output生成默认配置
+func (c *Config) GetProfiles() []ProfileConfig {
+	formats := c.EnabledFormats()
+	if len(c.Profiles) == 0 {
+		return []ProfileConfig{{
+			Name:    DefaultProfileName,
+			Token:   c.App.APIKey,
+			Filter:  c.NodeProcess.Filter,
+			Select:  c.NodeProcess.Select,
+			Rename:  c.NodeProcess.Rename,
+			Formats: formats,
+		}}
+	}
+
+	// 未指定格式的配置使用output.formats中启用的格式
+	profiles := make([]ProfileConfig, len(c.Profiles))
+	for i, profile := range c.Profiles {
+		if len(profile.Formats) == 0 {
+			profile.Formats = formats
+		}
+		profiles[i] = profile
+	}
+	return profiles
+}
+
+// EnabledFormats 获取output.formats中启用的格式
+func (c *Config) EnabledFormats() []string {
+	formats := make([]string, 0, len(c.Output.Formats))
+	for _, format := range c.Output.Formats {
+		if format.Enable {
+			formats = append(formats, format.Type)
+		}
+	}
+	return formats
+}
+
+// GetProfile 根据名称获取输出配置
+func (c *Config) GetProfile(name string) (ProfileConfig, bool) {
+	for _, profile := range c.GetProfiles() {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+	return ProfileConfig{}, false
+}
+
 // 创建默认配置
 func createDefaultConfig(file string) (*Config, error) {
 	// 创建目录
@@ -176,8 +610,9 @@ func createDefaultConfig(file string) (*Config, error) {
 				Enable:    true,
 				Timeout:   10,
 				RetryCount: 2,
-				OpenAIKey: "", // 需要用户配置
-				GeminiKey: "", // 需要用户配置
+				OpenAIKey: "", // 仅在开启付费检测时使用
+				GeminiKey: "", // 仅在开启付费检测时使用
+				PaidProbe: false,
 				List: []APIItemConfig{
 					{
 						Name:   "OpenAI",
@@ -214,8 +649,14 @@ func createDefaultConfig(file string) (*Config, error) {
 				},
 			},
 			IPQuality: IPQualityConfig{
-				Enable:  true,
-				Timeout: 10,
+				Enable:      true,
+				Timeout:     10,
+				Providers:   []string{"mmdb", "ip2region", "ipinfo", "ip-api", "ip.sb"},
+				CacheTTL:    360,
+				BanLists:    []string{"./ip/ban.txt", "./ip/banv6.txt"},
+				UnbanLists:  []string{"./ip/unban.txt", "./ip/unbanv6.txt"},
+				DNSBL:       []string{"zen.spamhaus.org", "bl.spamcop.net", "b.barracudacentral.org"},
+				ProxyDetect: true,
 			},
 		},
 		NodeProcess: NodeProcessConfig{
@@ -224,15 +665,26 @@ func createDefaultConfig(file string) (*Config, error) {
 				AddPrefix: "",
 				AddSuffix: "",
 				Template:  "{名称}|{国家}{速度}{API}",
+				Language:  "zh",
+				Dedupe:    true,
 			},
 			Filter: FilterConfig{
 				Enable:          true,
 				IncludeKeywords: []string{},
 				ExcludeKeywords: []string{},
 			},
+			Select: SelectConfig{
+				SortBy: []string{"latency"},
+			},
 		},
 		Output: OutputConfig{
-			LocalPath:  "./output",
+			LocalPath: "./output",
+			Versions:  5,
+			SafetyGate: SafetyGateConfig{
+				Enable:         true,
+				MinNodes:       1,
+				MaxDropPercent: 50,
+			},
 			Formats: []FormatConfig{
 				{
 					Type:   "clash",
@@ -246,6 +698,26 @@ func createDefaultConfig(file string) (*Config, error) {
 					Type:   "v2ray",
 					Enable: true,
 				},
+				{
+					Type:   "surge",
+					Enable: false,
+				},
+				{
+					Type:   "quanx",
+					Enable: false,
+				},
+				{
+					Type:   "loon",
+					Enable: false,
+				},
+				{
+					Type:   "shadowrocket",
+					Enable: false,
+				},
+				{
+					Type:   "stash",
+					Enable: false,
+				},
 			},
 			GistSave:  false,
 			GistToken: "",
@@ -256,6 +728,37 @@ func createDefaultConfig(file string) (*Config, error) {
 				"singbox.json",
 				"base64.txt",
 			},
+			Clash: ClashOutputConfig{
+				TestURL:  "http://www.gstatic.com/generate_204",
+				Interval: 300,
+				RegionGroups: RegionGroupConfig{
+					Enable:   true,
+					Type:     "url-test",
+					MinNodes: 1,
+				},
+				ServiceGroups: []ServiceGroupConfig{
+					{Name: "🤖 AI", APIs: []string{"OpenAI", "ChatGPT", "Claude", "Gemini"}},
+					{Name: "🎬 流媒体", APIs: []string{"Netflix", "Disney+", "YouTubePremium", "PrimeVideo"}},
+				},
+				Final: "DIRECT",
+				ProxyProvider: ProxyProviderConfig{
+					Name:     "nodes",
+					Interval: 3600,
+				},
+			},
+			SingBox: SingBoxOutputConfig{
+				Version:   "1.11",
+				MixedPort: 7890,
+				TestURL:   "http://www.gstatic.com/generate_204",
+				Interval:  300,
+				DNSRemote: "https://1.1.1.1/dns-query",
+				DNSDirect: "https://223.5.5.5/dns-query",
+				RuleSets: []SingBoxRuleSetConfig{
+					{Tag: "geosite-cn", Type: "remote", URL: "https://raw.githubusercontent.com/SagerNet/sing-geosite/rule-set/geosite-cn.srs", Outbound: "direct"},
+					{Tag: "geoip-cn", Type: "remote", URL: "https://raw.githubusercontent.com/SagerNet/sing-geoip/rule-set/geoip-cn.srs", Outbound: "direct"},
+				},
+				Final: "Proxy",
+			},
 		},
 		Tasks: []TaskConfig{
 			{
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestValidateDedupeFormat 重名编号格式必须依次包含一个%s与一个整数占位符
+func TestValidateDedupeFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		valid  bool
+	}{
+		{"", true},
+		{"%s %02d", true},
+		{"%s-%d", true},
+		{"%s #%x", true},
+		{"100%% %s %3d", true},
+		// 不含编号的格式会生成相同的名称，导致编号无法结束
+		{"%s", false},
+		{"%s %s", false},
+		{"JP", false},
+		{"%d %s", false},
+		{"%s %d %d", false},
+		{"%s %v", false},
+		{"%s %*d", false},
+		{"%s %[1]d", false},
+		{"%s %", false},
+	}
+	for _, test := range tests {
+		if err := ValidateDedupeFormat(test.format); (err == nil) != test.valid {
+			t.Errorf("ValidateDedupeFormat(%q) = %v，期望有效: %v", test.format, err, test.valid)
+		}
+	}
+
+	// 配置中的无效格式在加载时被拒绝
+	cfg := &Config{}
+	cfg.NodeProcess.Rename.DedupeFormat = "%s"
+	if err := cfg.Validate(); err == nil {
+		t.Error("无效的dedupe-format应导致配置校验失败")
+	}
+}
+
+// TestValidateProfiles 输出配置的名称、格式与过滤配置
+func TestValidateProfiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		profiles []ProfileConfig
+		valid    bool
+	}{
+		{"未配置", nil, true},
+		{"有效", []ProfileConfig{
+			{Name: "mobile", Formats: []string{"clash", "v2ray"}},
+			{Name: "pc_JP-1", Select: SelectConfig{SortBy: []string{"-speed"}}},
+		}, true},
+		{"名称为空", []ProfileConfig{{Name: ""}}, false},
+		{"名称包含路径", []ProfileConfig{{Name: "../etc"}}, false},
+		{"名称重复", []ProfileConfig{{Name: "mobile"}, {Name: "mobile"}}, false},
+		{"不支持的格式", []ProfileConfig{{Name: "mobile", Formats: []string{"xml"}}}, false},
+		{"无效的过滤表达式", []ProfileConfig{{Name: "mobile", Filter: FilterConfig{Expression: "country in ("}}}, false},
+		{"未知的排序键", []ProfileConfig{{Name: "mobile", Select: SelectConfig{SortBy: []string{"price"}}}}, false},
+	}
+	for _, test := range tests {
+		cfg := &Config{Profiles: test.profiles}
+		if err := cfg.Validate(); (err == nil) != test.valid {
+			t.Errorf("%s: 校验结果为 %v，期望有效: %v", test.name, err, test.valid)
+		}
+	}
+}
+
+// TestGetProfiles 未配置输出配置时使用全局配置生成默认配置，令牌为app.api-key
+func TestGetProfiles(t *testing.T) {
+	cfg := &Config{}
+	cfg.App.APIKey = "secret"
+	cfg.NodeProcess.Filter.Expression = `country == "JP"`
+	cfg.NodeProcess.Select.MaxNodes = 10
+	cfg.Output.Formats = []FormatConfig{{Type: "clash", Enable: true}, {Type: "singbox"}, {Type: "v2ray", Enable: true}}
+
+	profiles := cfg.GetProfiles()
+	if len(profiles) != 1 {
+		t.Fatalf("默认配置数量为 %d", len(profiles))
+	}
+	profile := profiles[0]
+	if profile.Name != DefaultProfileName || profile.Token != "secret" ||
+		profile.Filter.Expression != `country == "JP"` || profile.Select.MaxNodes != 10 ||
+		!reflect.DeepEqual(profile.Formats, []string{"clash", "v2ray"}) {
+		t.Errorf("默认配置为 %+v", profile)
+	}
+
+	// 命名配置不使用app.api-key，未指定格式时使用启用的格式
+	cfg.Profiles = []ProfileConfig{
+		{Name: "mobile", Formats: []string{"singbox"}},
+		{Name: "pc", Token: "pc-token"},
+	}
+	profiles = cfg.GetProfiles()
+	if len(profiles) != 2 || profiles[0].Token != "" || !reflect.DeepEqual(profiles[0].Formats, []string{"singbox"}) ||
+		profiles[1].Token != "pc-token" || !reflect.DeepEqual(profiles[1].Formats, []string{"clash", "v2ray"}) {
+		t.Errorf("输出配置为 %+v", profiles)
+	}
+	if cfg.Profiles[1].Formats != nil {
+		t.Error("GetProfiles修改了原配置")
+	}
+}
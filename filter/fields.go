@@ -0,0 +1,152 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// valueKind 字段值类型
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindNumber
+	kindBool
+)
+
+func (k valueKind) String() string {
+	switch k {
+	case kindNumber:
+		return "数字"
+	case kindBool:
+		return "布尔"
+	default:
+		return "字符串"
+	}
+}
+
+// field 可用于过滤的节点字段
+type field struct {
+	kind valueKind
+	get  func(node *model.ProxyNode) interface{}
+}
+
+// ipInfo 返回出口IP信息，不存在时返回空值
+func ipInfo(node *model.ProxyNode) *model.IPInfo {
+	if node.IPInfo == nil {
+		return &model.IPInfo{}
+	}
+	return node.IPInfo
+}
+
+// ipQuality 返回出口IP质量，不存在时返回空值
+func ipQuality(node *model.ProxyNode) *model.IPQuality {
+	if node.IPInfo == nil || node.IPInfo.Quality == nil {
+		return &model.IPQuality{}
+	}
+	return node.IPInfo.Quality
+}
+
+// fields 节点字段表，字段名不区分大小写
+var fields = map[string]field{
+	"name":         {kindString, func(n *model.ProxyNode) interface{} { return n.Name }},
+	"type":         {kindString, func(n *model.ProxyNode) interface{} { return n.Type }},
+	"server":       {kindString, func(n *model.ProxyNode) interface{} { return n.Server }},
+	"port":         {kindNumber, func(n *model.ProxyNode) interface{} { return float64(n.Port) }},
+	"cipher":       {kindString, func(n *model.ProxyNode) interface{} { return n.Cipher }},
+	"network":      {kindString, func(n *model.ProxyNode) interface{} { return n.Network }},
+	"tls":          {kindBool, func(n *model.ProxyNode) interface{} { return n.TLS }},
+	"udp":          {kindBool, func(n *model.ProxyNode) interface{} { return n.UDP }},
+	"sni":          {kindString, func(n *model.ProxyNode) interface{} { return n.SNI }},
+	"host":         {kindString, func(n *model.ProxyNode) interface{} { return n.Host }},
+	"latency":      {kindNumber, func(n *model.ProxyNode) interface{} { return float64(n.Latency) }},
+	"speed":        {kindNumber, func(n *model.ProxyNode) interface{} { return float64(n.Speed) }},
+	"active":       {kindBool, func(n *model.ProxyNode) interface{} { return n.Active }},
+	"success_rate": {kindNumber, func(n *model.ProxyNode) interface{} { return float64(n.SuccessRate) }},
+	"outlet_ip":    {kindString, func(n *model.ProxyNode) interface{} { return n.OutletIP }},
+	"entry_ip":     {kindString, func(n *model.ProxyNode) interface{} { return n.EntryIP }},
+	"route":        {kindString, func(n *model.ProxyNode) interface{} { return n.Route }},
+	"group":        {kindString, func(n *model.ProxyNode) interface{} { return n.GroupID }},
+	"country":      {kindString, func(n *model.ProxyNode) interface{} { return ipInfo(n).CountryCode }},
+	"country_name": {kindString, func(n *model.ProxyNode) interface{} { return ipInfo(n).Country }},
+	"region":       {kindString, func(n *model.ProxyNode) interface{} { return ipInfo(n).Region }},
+	"city":         {kindString, func(n *model.ProxyNode) interface{} { return ipInfo(n).City }},
+	"isp":          {kindString, func(n *model.ProxyNode) interface{} { return ipInfo(n).ISP }},
+	"asn":          {kindString, func(n *model.ProxyNode) interface{} { return ipInfo(n).ASN }},
+	"org":          {kindString, func(n *model.ProxyNode) interface{} { return ipInfo(n).Org }},
+	"inferred":     {kindBool, func(n *model.ProxyNode) interface{} { return ipInfo(n).Inferred }},
+	"ip_type":      {kindString, func(n *model.ProxyNode) interface{} { return ipQuality(n).Type }},
+	"ip_score":     {kindNumber, func(n *model.ProxyNode) interface{} { return float64(ipQuality(n).Score) }},
+	"ip_proxy":     {kindBool, func(n *model.ProxyNode) interface{} { return ipQuality(n).Proxy }},
+	"ip_banned":    {kindBool, func(n *model.ProxyNode) interface{} { return ipQuality(n).Banned }},
+	"entry_country": {kindString, func(n *model.ProxyNode) interface{} {
+		if n.EntryIPInfo == nil {
+			return ""
+		}
+		return n.EntryIPInfo.CountryCode
+	}},
+}
+
+// apiFields 检测结果字段，形如 api.Netflix.region，省略字段时表示是否可用
+var apiFields = map[string]valueKind{
+	"":          kindBool,
+	"available": kindBool,
+	"status":    kindString,
+	"region":    kindString,
+	"reason":    kindString,
+	"latency":   kindNumber,
+	"tag":       kindString,
+}
+
+// resolveField 解析字段路径
+func resolveField(path string) (field, error) {
+	lower := strings.ToLower(path)
+	if f, ok := fields[lower]; ok {
+		return f, nil
+	}
+
+	if !strings.HasPrefix(lower, "api.") {
+		return field{}, fmt.Errorf("未知的字段: %s", path)
+	}
+
+	// 检测项名称区分大小写，与配置中的名称一致
+	rest := path[len("api."):]
+	name, attr := rest, ""
+	if i := strings.LastIndex(rest, "."); i >= 0 {
+		if _, ok := apiFields[strings.ToLower(rest[i+1:])]; ok {
+			name, attr = rest[:i], strings.ToLower(rest[i+1:])
+		}
+	}
+	if name == "" {
+		return field{}, fmt.Errorf("缺少检测项名称: %s", path)
+	}
+
+	kind := apiFields[attr]
+	return field{kind: kind, get: func(n *model.ProxyNode) interface{} {
+		result := n.APIResults[name]
+		switch attr {
+		case "", "available":
+			return result != nil && result.Status.Available()
+		case "latency":
+			if result == nil {
+				return float64(0)
+			}
+			return float64(result.Latency)
+		}
+		if result == nil {
+			return ""
+		}
+		switch attr {
+		case "status":
+			return string(result.Status)
+		case "region":
+			return result.Region
+		case "reason":
+			return result.Reason
+		default:
+			return result.Tag
+		}
+	}}, nil
+}
@@ -0,0 +1,384 @@
+// Package filter 实现节点过滤表达式
+//
+// 表达式示例：
+//
+//	country in ("JP", "SG") && latency < 300 && api.OpenAI
+//	type != "ss" and name =~ "(?i)iplc"
+//	speed >= 1024 || success_rate > 90
+//
+// 支持的运算符：== != < <= > >= =~ !~ in (not in) contains，逻辑运算 && || !（也可写作 and or not）。
+// 单独的字段表示其值为真：布尔为true、数字非0、字符串非空。
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// Filter 已编译的过滤表达式
+type Filter struct {
+	source string
+	root   node
+}
+
+// node 表达式语法树节点
+type node interface {
+	eval(n *model.ProxyNode) bool
+}
+
+// Compile 编译过滤表达式，空表达式匹配所有节点
+func Compile(source string) (*Filter, error) {
+	f := &Filter{source: source}
+	if strings.TrimSpace(source) == "" {
+		return f, nil
+	}
+
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("位置 %d: 多余的内容 %q", tok.pos, tok.text)
+	}
+
+	f.root = root
+	return f, nil
+}
+
+// Validate 检查表达式是否有效
+func Validate(source string) error {
+	_, err := Compile(source)
+	return err
+}
+
+// String 返回表达式原文
+func (f *Filter) String() string {
+	return f.source
+}
+
+// Match 判断节点是否满足表达式
+func (f *Filter) Match(n *model.ProxyNode) bool {
+	if f == nil || f.root == nil {
+		return true
+	}
+	return f.root.eval(n)
+}
+
+// Apply 返回满足表达式的节点
+func (f *Filter) Apply(nodes []*model.ProxyNode) []*model.ProxyNode {
+	if f == nil || f.root == nil {
+		return nodes
+	}
+
+	result := make([]*model.ProxyNode, 0, len(nodes))
+	for _, n := range nodes {
+		if f.Match(n) {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// parser 递归下降语法分析
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// isOperator 判断当前词法单元是否为指定运算符
+func (p *parser) isOperator(op string) bool {
+	tok := p.peek()
+	return tok.kind == tokenOperator && tok.text == op
+}
+
+// parseOr or := and ('||' and)*
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAnd and := not ('&&' not)*
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseNot not := '!' not | primary
+func (p *parser) parseNot() (node, error) {
+	if p.isOperator("!") {
+		p.next()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{inner: inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary primary := '(' or ')' | comparison
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, fmt.Errorf("位置 %d: 缺少右括号", closing.pos)
+		}
+		return inner, nil
+	case tokenIdent:
+		return p.parseComparison(tok)
+	case tokenEOF:
+		return nil, fmt.Errorf("表达式不完整")
+	default:
+		return nil, fmt.Errorf("位置 %d: 此处应为字段名，实际为 %q", tok.pos, tok.text)
+	}
+}
+
+// parseComparison comparison := field [op value]
+func (p *parser) parseComparison(ident token) (node, error) {
+	f, err := resolveField(ident.text)
+	if err != nil {
+		return nil, fmt.Errorf("位置 %d: %v", ident.pos, err)
+	}
+
+	// not in
+	if p.isOperator("!") && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].text == "in" {
+		p.pos += 2
+		values, err := p.parseList(f.kind)
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{inner: &inNode{field: f, values: values}}, nil
+	}
+
+	tok := p.peek()
+	if tok.kind != tokenOperator || tok.text == "&&" || tok.text == "||" || tok.text == "!" {
+		return &truthyNode{field: f}, nil
+	}
+	op := p.next().text
+
+	switch op {
+	case "in":
+		values, err := p.parseList(f.kind)
+		if err != nil {
+			return nil, err
+		}
+		return &inNode{field: f, values: values}, nil
+	case "=~", "!~":
+		pattern := p.next()
+		if pattern.kind != tokenString {
+			return nil, fmt.Errorf("位置 %d: %s 右侧应为正则表达式字符串", pattern.pos, op)
+		}
+		re, err := regexp.Compile(pattern.value.(string))
+		if err != nil {
+			return nil, fmt.Errorf("位置 %d: 无效的正则表达式: %v", pattern.pos, err)
+		}
+		return &regexNode{field: f, re: re, negate: op == "!~"}, nil
+	case "contains":
+		value := p.next()
+		if value.kind != tokenString {
+			return nil, fmt.Errorf("位置 %d: contains 右侧应为字符串", value.pos)
+		}
+		return &containsNode{field: f, value: strings.ToLower(value.value.(string))}, nil
+	}
+
+	value, err := p.parseValue(f.kind)
+	if err != nil {
+		return nil, err
+	}
+	if (op == "<" || op == "<=" || op == ">" || op == ">=") && f.kind != kindNumber {
+		return nil, fmt.Errorf("位置 %d: %s 字段 %s 不支持 %s 比较", ident.pos, f.kind, ident.text, op)
+	}
+	return &compareNode{field: f, op: op, value: value}, nil
+}
+
+// parseList list := '(' value (',' value)* ')'
+func (p *parser) parseList(kind valueKind) ([]interface{}, error) {
+	if open := p.next(); open.kind != tokenLParen {
+		return nil, fmt.Errorf("位置 %d: in 右侧应为括号包围的列表", open.pos)
+	}
+
+	var values []interface{}
+	for {
+		value, err := p.parseValue(kind)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		tok := p.next()
+		if tok.kind == tokenRParen {
+			return values, nil
+		}
+		if tok.kind != tokenComma {
+			return nil, fmt.Errorf("位置 %d: 列表中应为逗号或右括号", tok.pos)
+		}
+	}
+}
+
+// parseValue 解析字面量并检查与字段类型是否一致
+func (p *parser) parseValue(kind valueKind) (interface{}, error) {
+	tok := p.next()
+
+	var value interface{}
+	var valueKind valueKind
+	switch {
+	case tok.kind == tokenString:
+		value, valueKind = tok.value, kindString
+	case tok.kind == tokenNumber:
+		value, valueKind = tok.value, kindNumber
+	case tok.kind == tokenIdent && (strings.EqualFold(tok.text, "true") || strings.EqualFold(tok.text, "false")):
+		value, valueKind = strings.EqualFold(tok.text, "true"), kindBool
+	default:
+		return nil, fmt.Errorf("位置 %d: 此处应为字符串、数字或布尔值", tok.pos)
+	}
+
+	if valueKind != kind {
+		return nil, fmt.Errorf("位置 %d: 字段类型为%s，值 %s 的类型为%s", tok.pos, kind, tok.text, valueKind)
+	}
+	return value, nil
+}
+
+// orNode 逻辑或
+type orNode struct{ left, right node }
+
+func (o *orNode) eval(n *model.ProxyNode) bool { return o.left.eval(n) || o.right.eval(n) }
+
+// andNode 逻辑与
+type andNode struct{ left, right node }
+
+func (a *andNode) eval(n *model.ProxyNode) bool { return a.left.eval(n) && a.right.eval(n) }
+
+// notNode 逻辑非
+type notNode struct{ inner node }
+
+func (o *notNode) eval(n *model.ProxyNode) bool { return !o.inner.eval(n) }
+
+// truthyNode 单独字段，判断其值是否为真
+type truthyNode struct{ field field }
+
+func (t *truthyNode) eval(n *model.ProxyNode) bool {
+	switch value := t.field.get(n).(type) {
+	case bool:
+		return value
+	case float64:
+		return value != 0
+	case string:
+		return value != ""
+	}
+	return false
+}
+
+// compareNode 比较运算
+type compareNode struct {
+	field field
+	op    string
+	value interface{}
+}
+
+func (c *compareNode) eval(n *model.ProxyNode) bool {
+	actual := c.field.get(n)
+	switch c.op {
+	case "==":
+		return actual == c.value
+	case "!=":
+		return actual != c.value
+	}
+
+	left, right := actual.(float64), c.value.(float64)
+	switch c.op {
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case ">":
+		return left > right
+	case ">=":
+		return left >= right
+	}
+	return false
+}
+
+// inNode 列表包含
+type inNode struct {
+	field  field
+	values []interface{}
+}
+
+func (i *inNode) eval(n *model.ProxyNode) bool {
+	actual := i.field.get(n)
+	for _, value := range i.values {
+		if actual == value {
+			return true
+		}
+	}
+	return false
+}
+
+// regexNode 正则匹配
+type regexNode struct {
+	field  field
+	re     *regexp.Regexp
+	negate bool
+}
+
+func (r *regexNode) eval(n *model.ProxyNode) bool {
+	return r.re.MatchString(fmt.Sprint(r.field.get(n))) != r.negate
+}
+
+// containsNode 子串匹配，不区分大小写
+type containsNode struct {
+	field field
+	value string
+}
+
+func (c *containsNode) eval(n *model.ProxyNode) bool {
+	return strings.Contains(strings.ToLower(fmt.Sprint(c.field.get(n))), c.value)
+}
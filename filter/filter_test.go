@@ -0,0 +1,154 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// testNode 用于表达式求值的节点
+func testNode() *model.ProxyNode {
+	return &model.ProxyNode{
+		Name:        "香港 IPLC 01",
+		Type:        "vmess",
+		Server:      "hk.example.com",
+		Port:        443,
+		Network:     "ws",
+		TLS:         true,
+		Latency:     120,
+		Speed:       2048,
+		Active:      true,
+		SuccessRate: 95,
+		IPInfo:      &model.IPInfo{CountryCode: "HK", ISP: "HKT"},
+		APIResults: map[string]*model.APIResult{
+			"Netflix": {Status: model.UnlockUnlocked, Region: "HK"},
+			"Disney+": {Status: model.UnlockBlocked},
+		},
+	}
+}
+
+// TestMatch 表达式对节点的求值结果
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{"type == \"vmess\"", true},
+		{"type != 'vmess'", false},
+		{"port == 443 && tls", true},
+		{"latency < 100", false},
+		{"latency <= 120 and speed >= 1024", true},
+		{"latency > 100.5", true},
+		{"port == -1", false},
+		{"success_rate > 90", true},
+		{"udp", false},
+		{"country", true},
+		{"country_name", false},
+
+		// 运算符优先级：! 高于 &&，&& 高于 ||
+		{"type == \"ss\" || type == \"vmess\" && port == 443", true},
+		{"(type == \"ss\" || type == \"vmess\") && port == 80", false},
+		{"type == \"ss\" && port == 80 || tls", true},
+		{"!tls || port == 443", true},
+		{"!(tls && port == 443)", false},
+		{"not udp", true},
+		{"NOT tls OR udp", false},
+		{"!!tls", true},
+
+		// 字符串与列表
+		{"country in (\"JP\", \"HK\")", true},
+		{"country not in (\"JP\", \"HK\")", false},
+		{"country !in (\"JP\", \"SG\")", true},
+		{"port in (80, 8080)", false},
+		{"name contains \"iplc\"", true},
+		{"isp contains \"cmi\"", false},
+		{"name =~ \"(?i)iplc\"", true},
+		{"name =~ \"^日本\"", false},
+		{"server !~ \"\\\\.cn$\"", true},
+		{"name == \"香港 IPLC 01\"", true},
+
+		// 检测结果
+		{"api.Netflix", true},
+		{"api.Netflix.region == \"HK\"", true},
+		{"api.Disney+", false},
+		{"api.Disney+.status == \"blocked\"", true},
+		{"api.YouTube", false},
+		{"api.YouTube.latency == 0", true},
+	}
+
+	node := testNode()
+	for _, test := range tests {
+		f, err := Compile(test.expr)
+		if err != nil {
+			t.Errorf("编译 %q 失败: %v", test.expr, err)
+			continue
+		}
+		if got := f.Match(node); got != test.want {
+			t.Errorf("%q 的结果为 %v，期望 %v", test.expr, got, test.want)
+		}
+	}
+}
+
+// TestCompileError 无效的表达式返回错误
+func TestCompileError(t *testing.T) {
+	for _, expr := range []string{
+		// 未知字段
+		"unknown == 1",
+		"api.",
+		// 语法错误
+		"type ==",
+		"type == \"ss",
+		"(tls",
+		"tls)",
+		"tls &&",
+		"&& tls",
+		"tls tls",
+		"country in \"HK\"",
+		"country in (\"HK\"",
+		"country in (\"HK\" \"JP\")",
+		"type # \"ss\"",
+		// 数字
+		"latency < 1.2.3",
+		"latency < 1..",
+		// 类型不一致
+		"port == \"443\"",
+		"type == 1",
+		"tls == 1",
+		"type < \"b\"",
+		"country in (\"HK\", 1)",
+		// 正则与contains
+		"name =~ iplc",
+		"name =~ \"(\"",
+		"name contains 1",
+	} {
+		if _, err := Compile(expr); err == nil {
+			t.Errorf("编译 %q 应失败", expr)
+		}
+	}
+}
+
+// TestApply 返回满足表达式的节点，保持原有顺序
+func TestApply(t *testing.T) {
+	hk := testNode()
+	jp := testNode()
+	jp.Name = "日本 01"
+	jp.IPInfo = &model.IPInfo{CountryCode: "JP"}
+	us := testNode()
+	us.Name = "美国 01"
+	us.IPInfo = nil
+
+	f, err := Compile("country in (\"HK\", \"JP\") && latency < 300")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := f.Apply([]*model.ProxyNode{jp, us, hk})
+	if len(result) != 2 || result[0] != jp || result[1] != hk {
+		t.Errorf("过滤结果为 %v", result)
+	}
+
+	var empty *Filter
+	if len(empty.Apply([]*model.ProxyNode{us})) != 1 {
+		t.Error("空过滤器应返回所有节点")
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -17,6 +18,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gin-contrib/cors"
 	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -50,6 +52,7 @@ func main() {
 
 	// API检查服务
 	apiCheckService := service.NewAPICheckService(cfg)
+	nodeService.SetAPICheckService(apiCheckService)
 
 	// 输出生成服务
 	outputGenerator := service.NewOutputGenerator(cfg)
@@ -75,11 +78,22 @@ func main() {
 				case "check":
 					nodeService.CheckAllNodes()
 				case "save":
-					nodes := nodeService.FilterNodes()
-					outputGenerator.SaveOutput(nodes)
+					// 每个输出配置保存一组文件
+					gated := false
+					for _, profile := range cfg.GetProfiles() {
+						nodes := nodeService.ProfileNodes(profile)
+						if err := service.NewProfileOutputGenerator(cfg, profile).SaveOutput(nodes); err != nil {
+							fmt.Printf("保存输出配置%s失败: %v\n", profile.Name, err)
+							if errors.Is(err, service.ErrSafetyGate) {
+								gated = true
+							}
+						}
+					}
 					
-					// 如果启用了Gist保存，则保存到Gist
-					if cfg.Output.GistSave {
+					// 如果启用了Gist保存，则保存到Gist，安全检查未通过时不上传
+					if cfg.Output.GistSave && gated {
+						fmt.Println("安全检查未通过，跳过保存到Gist")
+					} else if cfg.Output.GistSave {
 						gistURL, err := gistService.SaveSubscriptionToGist(subscriptionService, outputGenerator)
 						if err != nil {
 							fmt.Printf("保存到Gist失败: %v\n", err)
@@ -290,7 +304,11 @@ func setupRouter(
 			})
 			
 			apiAuth.GET("/nodes", func(c *gin.Context) {
-				nodes := nodeService.FilterNodes()
+				nodes, err := nodeService.FilterNodesBy(c.Query("filter"))
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "过滤表达式无效: " + err.Error()})
+					return
+				}
 				c.JSON(http.StatusOK, nodes)
 			})
 			
@@ -299,50 +317,273 @@ func setupRouter(
 				c.JSON(http.StatusOK, gin.H{"success": true, "message": "节点检测已启动"})
 			})
 			
+			// 重命名预览：GET使用当前配置（可用template参数覆盖模板），POST使用请求体中的rename配置（YAML或JSON）
+			renamePreview := func(c *gin.Context) {
+				renameCfg := cfg.NodeProcess.Rename
+				if c.Request.Method == http.MethodPost {
+					body, err := c.GetRawData()
+					if err == nil {
+						renameCfg = config.RenameConfig{}
+						err = yaml.Unmarshal(body, &renameCfg)
+					}
+					if err != nil {
+						c.JSON(http.StatusBadRequest, gin.H{"error": "无效的重命名配置: " + err.Error()})
+						return
+					}
+				}
+				if template := c.Query("template"); template != "" {
+					renameCfg.Template = template
+				}
+				// 请求中指定了重命名配置或模板时，即使未启用重命名也按其预览
+				if c.Request.Method == http.MethodPost || c.Query("template") != "" {
+					renameCfg.Enable = true
+				}
+				
+				previews, err := nodeService.PreviewRename(&renameCfg, c.Query("filter"))
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusOK, previews)
+			}
+			apiAuth.GET("/rename/preview", renamePreview)
+			apiAuth.POST("/rename/preview", renamePreview)
+			
+			// 订阅转换：GET使用url参数指定订阅，POST的请求体为订阅内容，不保存订阅与节点
+			convert := func(c *gin.Context) {
+				req := service.ConvertRequest{
+					URL:      c.Query("url"),
+					Type:     c.Query("type"),
+					Format:   c.Query("format"),
+					Filter:   c.Query("filter"),
+					Rename:   c.Query("rename"),
+					Template: c.Query("template"),
+				}
+				if c.Request.Method == http.MethodPost && req.URL == "" {
+					body, err := c.GetRawData()
+					if err != nil {
+						c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求体"})
+						return
+					}
+					req.Content = string(body)
+				}
+				// 未指定格式时根据User-Agent识别客户端
+				if req.Format == "" {
+					req.Format = clientFormat(c, nil, "clash")
+				}
+				
+				content, sub, err := service.Convert(cfg, req)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				
+				setContentHeaders(c, req.Format)
+				if userinfo := service.FormatSubscriptionUserinfo([]*model.Subscription{sub}); userinfo != "" {
+					c.Header("subscription-userinfo", userinfo)
+				}
+				c.String(http.StatusOK, content)
+			}
+			apiAuth.GET("/convert", convert)
+			apiAuth.POST("/convert", convert)
+			
+			// 输出版本：列出历史版本，回滚到指定版本
+			apiAuth.GET("/output/:profile/versions", func(c *gin.Context) {
+				profile, ok := cfg.GetProfile(c.Param("profile"))
+				if !ok {
+					c.JSON(http.StatusNotFound, gin.H{"error": "输出配置不存在"})
+					return
+				}
+				
+				versions, err := service.NewProfileOutputGenerator(cfg, profile).Versions()
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusOK, versions)
+			})
+			
+			apiAuth.POST("/output/:profile/rollback/:version", func(c *gin.Context) {
+				profile, ok := cfg.GetProfile(c.Param("profile"))
+				if !ok {
+					c.JSON(http.StatusNotFound, gin.H{"error": "输出配置不存在"})
+					return
+				}
+				
+				version, err := service.NewProfileOutputGenerator(cfg, profile).Rollback(c.Param("version"))
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusOK, version)
+			})
+			
 			apiAuth.GET("/config", func(c *gin.Context) {
+				// 未指定格式时根据User-Agent识别客户端
 				format := c.Query("format")
 				if format == "" {
-					format = "clash" // 默认格式
+					format = clientFormat(c, nil, "clash") // 默认格式
 				}
 				
 				var content string
-				var err error
-				nodes := nodeService.FilterNodes()
+				nodes, err := nodeService.FilterNodesBy(c.Query("filter"))
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "过滤表达式无效: " + err.Error()})
+					return
+				}
 				
-				switch format {
-				case "clash":
-					content, err = outputGenerator.GenerateClashConfig(nodes)
-				case "singbox":
-					content, err = outputGenerator.GenerateSingBoxConfig(nodes)
-				case "v2ray":
-					content, err = outputGenerator.GenerateBase64Config(nodes)
-				default:
+				if !config.IsOutputFormat(format) {
 					c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的格式"})
 					return
 				}
 				
+				content, err = outputGenerator.Generate(format, nodes)
 				if err != nil {
 					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 					return
 				}
 				
-				// 根据格式设置Content-Type
-				switch format {
-				case "clash":
-					c.Header("Content-Type", "text/yaml")
-					c.Header("Content-Disposition", "attachment; filename=clash.yaml")
-				case "singbox":
-					c.Header("Content-Type", "application/json")
-					c.Header("Content-Disposition", "attachment; filename=singbox.json")
-				case "v2ray":
-					c.Header("Content-Type", "text/plain")
-					c.Header("Content-Disposition", "attachment; filename=v2ray.txt")
-				}
-				
+				setContentHeaders(c, format)
+				setUserinfoHeader(c, subscriptionService, nodes)
 				c.String(http.StatusOK, content)
 			})
 		}
 	}
 	
+	// 命名输出配置的订阅地址，使用各配置自己的访问令牌
+	router.GET("/sub/:profile", func(c *gin.Context) {
+		profile, ok := authorizeProfile(c, cfg)
+		if !ok {
+			return
+		}
+		
+		if len(profile.Formats) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "输出配置未启用任何格式"})
+			return
+		}
+		format := c.Query("format")
+		if format == "" {
+			format = clientFormat(c, profile.Formats, profile.Formats[0])
+		}
+		if !containsFormat(profile.Formats, format) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "输出配置不支持该格式: " + format})
+			return
+		}
+		
+		nodes := nodeService.ProfileNodes(profile)
+		content, err := service.NewProfileOutputGenerator(cfg, profile).Generate(format, nodes)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		
+		setContentHeaders(c, format)
+		setUserinfoHeader(c, subscriptionService, nodes)
+		c.String(http.StatusOK, content)
+	})
+	
+	// 输出配置的代理集，只包含节点列表，format为clash（Clash代理集）或singbox（sing-box出站集）
+	router.GET("/provider/:profile", func(c *gin.Context) {
+		profile, ok := authorizeProfile(c, cfg)
+		if !ok {
+			return
+		}
+		
+		// 未指定格式时根据User-Agent识别客户端
+		format := c.Query("format")
+		if format == "" {
+			format = clientFormat(c, []string{"clash", "singbox"}, "clash")
+		}
+		
+		nodes := nodeService.ProfileNodes(profile)
+		generator := service.NewProfileOutputGenerator(cfg, profile)
+		var content string
+		var err error
+		switch format {
+		case "clash":
+			content, err = generator.GenerateClashProvider(nodes)
+			c.Header("Content-Type", "text/yaml")
+		case "singbox":
+			content, err = generator.GenerateSingBoxProvider(nodes)
+			c.Header("Content-Type", "application/json")
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "代理集不支持该格式: " + format})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		
+		setUserinfoHeader(c, subscriptionService, nodes)
+		c.String(http.StatusOK, content)
+	})
+	
 	return router
+}
+
+// authorizeProfile 获取请求的输出配置并校验访问令牌，失败时已写入错误响应
+func authorizeProfile(c *gin.Context, cfg *config.Config) (config.ProfileConfig, bool) {
+	profile, ok := cfg.GetProfile(c.Param("profile"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "输出配置不存在"})
+		return profile, false
+	}
+	
+	if profile.Token != "" && c.Query("token") != profile.Token {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的访问令牌"})
+		return profile, false
+	}
+	return profile, true
+}
+
+// setContentHeaders 根据格式设置Content-Type与下载文件名
+func setContentHeaders(c *gin.Context, format string) {
+	switch format {
+	case "clash":
+		c.Header("Content-Type", "text/yaml")
+		c.Header("Content-Disposition", "attachment; filename=clash.yaml")
+	case "singbox":
+		c.Header("Content-Type", "application/json")
+		c.Header("Content-Disposition", "attachment; filename=singbox.json")
+	case "v2ray":
+		c.Header("Content-Type", "text/plain")
+		c.Header("Content-Disposition", "attachment; filename=v2ray.txt")
+	case "surge", "quanx", "loon":
+		c.Header("Content-Type", "text/plain")
+		c.Header("Content-Disposition", "attachment; filename="+format+".conf")
+	case "shadowrocket":
+		c.Header("Content-Type", "text/plain")
+		c.Header("Content-Disposition", "attachment; filename=shadowrocket.txt")
+	case "stash":
+		c.Header("Content-Type", "text/yaml")
+		c.Header("Content-Disposition", "attachment; filename=stash.yaml")
+	}
+}
+
+// clientFormat 根据User-Agent选择客户端支持的格式，formats为可用的格式，为空时不限制，无法识别时返回fallback
+func clientFormat(c *gin.Context, formats []string, fallback string) string {
+	for _, format := range service.DetectClientFormats(c.GetHeader("User-Agent")) {
+		if len(formats) == 0 || containsFormat(formats, format) {
+			return format
+		}
+	}
+	return fallback
+}
+
+// setUserinfoHeader 设置subscription-userinfo响应头，客户端据此显示订阅的流量与到期时间
+func setUserinfoHeader(c *gin.Context, subscriptionService *service.SubscriptionService, nodes []*model.ProxyNode) {
+	if userinfo := subscriptionService.SubscriptionUserinfo(nodes); userinfo != "" {
+		c.Header("subscription-userinfo", userinfo)
+	}
+}
+
+// containsFormat 判断格式列表中是否包含指定格式
+func containsFormat(formats []string, format string) bool {
+	for _, item := range formats {
+		if item == format {
+			return true
+		}
+	}
+	return false
 } 
\ No newline at end of file
@@ -1,15 +1,64 @@
 package model
 
 import (
+	"encoding/json"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 )
 
+// UnlockStatus 解锁检测结果类型
+type UnlockStatus string
+
+const (
+	UnlockUnlocked      UnlockStatus = "unlocked"       // 完全解锁
+	UnlockBlocked       UnlockStatus = "blocked"        // 不可用
+	UnlockRegionOnly    UnlockStatus = "region-only"    // 仅限部分地区/服务
+	UnlockOriginalsOnly UnlockStatus = "originals-only" // 仅自制内容（如Netflix自制剧）
+)
+
+// Available 是否视为可用（除blocked外均视为可用）
+func (s UnlockStatus) Available() bool {
+	return s != "" && s != UnlockBlocked
+}
+
+// APIResult 单项解锁检测结果
+type APIResult struct {
+	Status     UnlockStatus `json:"status"`                // 检测结果
+	Tag        string       `json:"tag"`                   // 重命名时使用的短标签
+	HTTPCode   int          `json:"http_code,omitempty"`   // 最后一次请求的HTTP状态码
+	Latency    int          `json:"latency"`               // 检测耗时(ms)
+	Region     string       `json:"region,omitempty"`      // 解锁地区代码，如JP、US
+	Reason     string       `json:"reason,omitempty"`      // 结果原因代码
+	ErrorClass string       `json:"error_class,omitempty"` // 网络错误类别：timeout, dns, tls, refused, reset, proxy, network
+	CheckedAt  time.Time    `json:"checked_at"`            // 检测时间
+}
+
+// Label 生成结果标签，带地区时形如 Netflix:JP，仅自制剧的结果带*号，不可用时为空
+func (r *APIResult) Label(name string) string {
+	if r == nil || !r.Status.Available() {
+		return ""
+	}
+	label := r.Tag
+	if label == "" {
+		label = name
+	}
+	if r.Status == UnlockOriginalsOnly {
+		label += "*"
+	}
+	if r.Region != "" {
+		label += ":" + r.Region
+	}
+	return label
+}
+
 // ProxyNode 代理节点
 type ProxyNode struct {
 	ID             string    `json:"id"`              // 节点唯一标识
-	Name           string    `json:"name"`            // 节点名称
+	Name           string    `json:"name"`            // 节点名称，源节点中始终为订阅中的原始名称
+	OriginalName   string    `json:"original_name,omitempty"` // 原始名称，仅在输出阶段处理后的副本中设置
 	Type           string    `json:"type"`            // 节点类型：ss, vmess, trojan等
 	Server         string    `json:"server"`          // 服务器地址
 	Port           int       `json:"port"`            // 端口
@@ -29,29 +78,60 @@ type ProxyNode struct {
 	Latency        int       `json:"latency"`         // 延迟(ms)
 	Speed          int       `json:"speed"`           // 速度(KB/s)
 	Active         bool      `json:"active"`          // 是否可用
-	APIConnectivity map[string]bool `json:"api_connectivity"` // API连通性测试结果
+	APIResults     map[string]*APIResult `json:"api_results,omitempty"` // 解锁检测详细结果，JSON中另附api_connectivity布尔视图
 	IPInfo         *IPInfo   `json:"ip_info,omitempty"` // IP信息
 	LastCheck      time.Time `json:"last_check"`      // 最后测试时间
 	SuccessRate    int       `json:"success_rate"`    // 成功率(0-100)
 	OutletIP       string    `json:"outlet_ip"`       // 出口IP
+	EntryIP        string    `json:"entry_ip"`        // 入口IP，由服务器地址解析得到
+	EntryIPInfo    *IPInfo   `json:"entry_ip_info,omitempty"` // 入口IP信息，IPInfo为出口IP信息
+	Route          string    `json:"route,omitempty"` // 线路类型：direct, relay, cdn
 	
 	// 原始数据，保存原节点信息，以便输出时使用
 	RawData        map[string]interface{} `json:"-"`
+	RawFormat      string    `json:"raw_format,omitempty"` // 原始数据的格式：clash
 	GroupID        string    `json:"groupid,omitempty"`  // 分组ID
+	SubscriptionID   string  `json:"subscription_id,omitempty"`   // 所属订阅ID
+	SubscriptionName string  `json:"subscription_name,omitempty"` // 所属订阅名称
 }
 
+// 原始数据格式
+const (
+	RawFormatClash = "clash" // Clash代理配置，分享链接也转换为该格式，可直接输出到Clash
+)
+
+// 线路类型
+const (
+	RouteDirect = "direct" // 直连，入口与出口相同
+	RouteRelay  = "relay"  // 中转，入口与出口位于不同网络
+	RouteCDN    = "cdn"    // 经CDN转发
+)
+
 // IPInfo IP信息
 type IPInfo struct {
-	Country     string  `json:"country"`      // 国家
-	CountryCode string  `json:"country_code"` // 国家代码
-	Region      string  `json:"region"`       // 地区
-	City        string  `json:"city"`         // 城市
-	ISP         string  `json:"isp"`          // ISP
-	ASN         string  `json:"asn"`          // ASN
-	Org         string  `json:"org"`          // 组织
-	Lat         float64 `json:"lat"`          // 纬度
-	Lon         float64 `json:"lon"`          // 经度
-	TimeZone    string  `json:"timezone"`     // 时区
+	Country     string     `json:"country"`           // 国家
+	CountryCode string     `json:"country_code"`      // 国家代码
+	Region      string     `json:"region"`            // 地区
+	City        string     `json:"city"`              // 城市
+	ISP         string     `json:"isp"`               // ISP
+	ASN         string     `json:"asn"`               // ASN
+	Org         string     `json:"org"`               // 组织
+	Lat         float64    `json:"lat"`               // 纬度
+	Lon         float64    `json:"lon"`               // 经度
+	TimeZone    string     `json:"timezone"`          // 时区
+	IP          string     `json:"ip"`                // 查询的IP
+	Source      string     `json:"source"`            // 数据来源，如mmdb、ipinfo，根据节点名称推断时为name
+	Inferred    bool       `json:"inferred"`          // 是否为根据节点名称推断的结果
+	Quality     *IPQuality `json:"quality,omitempty"` // IP质量
+}
+
+// IPQuality IP质量
+type IPQuality struct {
+	Type       string   `json:"type"`       // IP类型：hosting, residential, mobile, unknown
+	Proxy      bool     `json:"proxy"`      // 是否被识别为代理/VPN
+	Blacklists []string `json:"blacklists"` // 命中的DNSBL黑名单
+	Banned     bool     `json:"banned"`     // 是否在本地封禁列表中
+	Score      int      `json:"score"`      // 综合评分，0-100，越高越干净
 }
 
 // Subscription 订阅信息
@@ -115,8 +195,71 @@ func (s *Subscription) GetRemainingDays() int {
 	return days
 }
 
-// RenameNode 重命名节点
-func (p *ProxyNode) RenameNode(template string) string {
+// Clone 复制节点，用于输出阶段在副本上修改名称等字段而不影响源节点
+func (p *ProxyNode) Clone() *ProxyNode {
+	clone := *p
+	if p.APIResults != nil {
+		clone.APIResults = make(map[string]*APIResult, len(p.APIResults))
+		for name, result := range p.APIResults {
+			clone.APIResults[name] = result
+		}
+	}
+	if p.RawData != nil {
+		clone.RawData = make(map[string]interface{}, len(p.RawData))
+		for key, value := range p.RawData {
+			clone.RawData[key] = value
+		}
+	}
+	return &clone
+}
+
+// SetAPIResult 记录解锁检测结果
+func (p *ProxyNode) SetAPIResult(name string, result *APIResult) {
+	if p.APIResults == nil {
+		p.APIResults = make(map[string]*APIResult)
+	}
+	p.APIResults[name] = result
+}
+
+// APIAvailable 判断指定检测项是否可用
+func (p *ProxyNode) APIAvailable(name string) bool {
+	result, ok := p.APIResults[name]
+	return ok && result.Status.Available()
+}
+
+// APIConnectivity API连通性的布尔视图，兼容旧版api_connectivity字段
+func (p *ProxyNode) APIConnectivity() map[string]bool {
+	connectivity := make(map[string]bool, len(p.APIResults))
+	for name, result := range p.APIResults {
+		connectivity[name] = result.Status.Available()
+	}
+	return connectivity
+}
+
+// MarshalJSON 序列化节点，附加api_connectivity布尔视图
+func (p ProxyNode) MarshalJSON() ([]byte, error) {
+	type alias ProxyNode
+	return json.Marshal(&struct {
+		alias
+		APIConnectivity map[string]bool `json:"api_connectivity"`
+	}{
+		alias:           alias(p),
+		APIConnectivity: p.APIConnectivity(),
+	})
+}
+
+// ipTypeNames IP类型在节点名称中的显示
+var ipTypeNames = map[string]string{
+	"hosting":     "机房",
+	"residential": "家宽",
+	"mobile":      "移动",
+}
+
+// apiVariablePattern 匹配单项检测结果变量 {API:名称} 与 {API:名称:字段}
+var apiVariablePattern = regexp.MustCompile(`\{API:([^:{}]+)(?::([^:{}]+))?\}`)
+
+// RenameNode 重命名节点，language为{国家名}的显示语言
+func (p *ProxyNode) RenameNode(template, language string) string {
 	// 如果模板为空，返回原名称
 	if template == "" {
 		return p.Name
@@ -132,6 +275,22 @@ func (p *ProxyNode) RenameNode(template string) string {
 		country = p.IPInfo.CountryCode
 	}
 	name = strings.ReplaceAll(name, "{国家}", country)
+	name = strings.ReplaceAll(name, "{国旗}", CountryFlag(country))
+	countryName, countryEnName := "", ""
+	if info, ok := LookupCountry(country); ok {
+		countryName = CountryName(country, language)
+		countryEnName = info.EnName
+	}
+	name = strings.ReplaceAll(name, "{国家名}", countryName)
+	name = strings.ReplaceAll(name, "{国家英文}", countryEnName)
+	
+	// 入口与出口国家
+	entry := ""
+	if p.EntryIPInfo != nil {
+		entry = p.EntryIPInfo.CountryCode
+	}
+	name = strings.ReplaceAll(name, "{入口}", entry)
+	name = strings.ReplaceAll(name, "{出口}", country)
 	
 	// 速度信息
 	speed := ""
@@ -154,25 +313,74 @@ func (p *ProxyNode) RenameNode(template string) string {
 	}
 	name = strings.ReplaceAll(name, "{成功率}", successRate)
 	
-	// API可用性标签
-	apiTags := ""
-	for api, ok := range p.APIConnectivity {
-		if ok {
-			if api == "OpenAI" {
-				apiTags += "Openai|"
-			} else if api == "Gemini" {
-				apiTags += "Gemini|"
-			} else if api == "YouTube" {
-				apiTags += "Youtube|"
-			} else if api == "Netflix" {
-				apiTags += "Netflix|"
-			}
-		}
+	// IP质量
+	ipType, ipScore := "", ""
+	if p.IPInfo != nil && p.IPInfo.Quality != nil {
+		ipType = ipTypeNames[p.IPInfo.Quality.Type]
+		ipScore = fmt.Sprintf("%d", p.IPInfo.Quality.Score)
 	}
-	if len(apiTags) > 0 {
-		apiTags = strings.TrimSuffix(apiTags, "|")
+	name = strings.ReplaceAll(name, "{IP类型}", ipType)
+	name = strings.ReplaceAll(name, "{IP评分}", ipScore)
+	
+	// API可用性标签，按检测名称排序保证输出稳定
+	names := make([]string, 0, len(p.APIResults))
+	for api := range p.APIResults {
+		names = append(names, api)
 	}
+	sort.Strings(names)
+	tags := make([]string, 0, len(names))
+	for _, api := range names {
+		result := p.APIResults[api]
+		if result == nil || !result.Status.Available() {
+			continue
+		}
+		tag := result.Tag
+		if tag == "" {
+			tag = api
+		}
+		tags = append(tags, tag)
+	}
+	apiTags := strings.Join(tags, "|")
 	name = strings.ReplaceAll(name, "{API}", apiTags)
 	
+	// 流媒体解锁地区，如 Netflix:JP|YTP:US，仅自制剧的结果带*号
+	media := make([]string, 0, len(names))
+	for _, api := range names {
+		result := p.APIResults[api]
+		if result == nil || result.Region == "" {
+			continue
+		}
+		if label := result.Label(api); label != "" {
+			media = append(media, label)
+		}
+	}
+	name = strings.ReplaceAll(name, "{流媒体}", strings.Join(media, "|"))
+	
+	// 单项检测结果，如 {API:Netflix} 或 {API:Netflix:延迟}
+	name = apiVariablePattern.ReplaceAllStringFunc(name, func(variable string) string {
+		match := apiVariablePattern.FindStringSubmatch(variable)
+		result := p.APIResults[match[1]]
+		switch match[2] {
+		case "":
+			return result.Label(match[1])
+		case "延迟":
+			if result == nil || result.Latency <= 0 {
+				return ""
+			}
+			return fmt.Sprintf("%dms", result.Latency)
+		case "地区":
+			if result == nil {
+				return ""
+			}
+			return result.Region
+		case "状态":
+			if result == nil {
+				return ""
+			}
+			return string(result.Status)
+		}
+		return variable
+	})
+	
 	return name
 } 
\ No newline at end of file
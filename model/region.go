@@ -0,0 +1,200 @@
+package model
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// regionKeyword 地区关键词
+type regionKeyword struct {
+	keyword string
+	code    string
+}
+
+// 中文地名与简称，国家名称会从国家列表中自动加入
+var chineseRegionAliases = map[string]string{
+	"臺灣": "TW", "台灣": "TW", "台北": "TW", "新北": "TW", "台中": "TW", "高雄": "TW", "彰化": "TW",
+	"東京": "JP", "东京": "JP", "大阪": "JP", "埼玉": "JP", "名古屋": "JP", "福冈": "JP", "日區": "JP",
+	"韓國": "KR", "首尔": "KR", "首爾": "KR", "春川": "KR",
+	"獅城": "SG", "狮城": "SG",
+	"美國": "US", "美西": "US", "美东": "US", "美東": "US", "洛杉矶": "US", "圣何塞": "US", "硅谷": "US",
+	"纽约": "US", "西雅图": "US", "芝加哥": "US", "达拉斯": "US", "凤凰城": "US", "波特兰": "US",
+	"迈阿密": "US", "亚特兰大": "US", "弗里蒙特": "US", "旧金山": "US", "新泽西": "US", "马里兰": "US",
+	"英國": "GB", "伦敦": "GB", "曼彻斯特": "GB",
+	"德國": "DE", "法兰克福": "DE", "柏林": "DE", "慕尼黑": "DE",
+	"法國": "FR", "巴黎": "FR", "马赛": "FR",
+	"阿姆斯特丹": "NL",
+	"澳洲":    "AU", "悉尼": "AU", "墨尔本": "AU",
+	"多伦多": "CA", "温哥华": "CA", "蒙特利尔": "CA",
+	"俄國": "RU", "莫斯科": "RU", "圣彼得堡": "RU", "伯力": "RU",
+	"孟买": "IN", "新德里": "IN",
+	"伊斯坦布尔": "TR",
+	"迪拜":    "AE",
+	"吉隆坡":   "MY",
+	"曼谷":    "TH",
+	"胡志明":   "VN", "河内": "VN",
+	"马尼拉": "PH",
+	"雅加达": "ID",
+	"澳門":  "MO",
+	"香港":  "HK",
+	"内蒙古": "CN", "上海": "CN", "北京": "CN", "广州": "CN", "深圳": "CN", "杭州": "CN",
+}
+
+// 英文地名与别称，国家英文名称会从国家列表中自动加入
+var englishRegionAliases = map[string]string{
+	"hongkong": "HK", "taipei": "TW", "tokyo": "JP", "osaka": "JP", "seoul": "KR",
+	"america": "US", "los angeles": "US", "san jose": "US", "silicon valley": "US", "new york": "US",
+	"seattle": "US", "chicago": "US", "dallas": "US", "miami": "US", "phoenix": "US", "new jersey": "US",
+	"fremont": "US", "san francisco": "US", "britain": "GB", "england": "GB", "london": "GB",
+	"frankfurt": "DE", "paris": "FR", "amsterdam": "NL", "holland": "NL", "sydney": "AU",
+	"toronto": "CA", "vancouver": "CA", "moscow": "RU", "mumbai": "IN", "istanbul": "TR",
+	"dubai": "AE", "korea": "KR", "kuala lumpur": "MY", "atlanta": "US",
+	// 节点名称中的Georgia通常指美国佐治亚州而不是格鲁吉亚
+	"georgia": "US",
+}
+
+// 代码与缩写，按单词匹配，需为大写
+var regionCodes = map[string]string{
+	// 常用国家/地区代码
+	"HK": "HK", "TW": "TW", "JP": "JP", "KR": "KR", "SG": "SG", "US": "US", "UK": "GB", "GB": "GB",
+	"DE": "DE", "FR": "FR", "NL": "NL", "RU": "RU", "CA": "CA", "AU": "AU", "IN": "IN", "TR": "TR",
+	"MY": "MY", "TH": "TH", "VN": "VN", "PH": "PH", "ID": "ID", "AR": "AR", "BR": "BR", "MO": "MO",
+	"CN": "CN", "IE": "IE", "IT": "IT", "ES": "ES", "CH": "CH", "SE": "SE", "PL": "PL", "UA": "UA",
+	"AE": "AE", "IL": "IL", "ZA": "ZA", "NZ": "NZ", "MX": "MX", "CL": "CL", "KZ": "KZ", "NG": "NG",
+	// 三位缩写
+	"USA": "US", "JPN": "JP", "HKG": "HK", "SGP": "SG", "KOR": "KR", "TWN": "TW", "GBR": "GB",
+	"DEU": "DE", "FRA": "FR", "NLD": "NL", "RUS": "RU", "CAN": "CA", "AUS": "AU", "IND": "IN",
+	"TUR": "TR", "MYS": "MY", "THA": "TH", "VNM": "VN", "PHL": "PH", "IDN": "ID", "UAE": "AE",
+	// IATA机场代码
+	"NRT": "JP", "HND": "JP", "KIX": "JP", "ICN": "KR", "GMP": "KR", "TPE": "TW", "TSA": "TW",
+	"SIN": "SG", "LAX": "US", "SJC": "US", "SFO": "US", "SEA": "US", "JFK": "US", "ORD": "US",
+	"DFW": "US", "IAD": "US", "ATL": "US", "MIA": "US", "PHX": "US", "LHR": "GB", "LCY": "GB",
+	"CDG": "FR", "AMS": "NL", "SYD": "AU", "MEL": "AU", "YYZ": "CA", "YVR": "CA", "SVO": "RU",
+	"DME": "RU", "BOM": "IN", "DEL": "IN", "DXB": "AE", "IST": "TR", "KUL": "MY", "BKK": "TH",
+	"SGN": "VN", "HAN": "VN", "MNL": "PH", "CGK": "ID", "GRU": "BR", "MFM": "MO", "PVG": "CN",
+	"PEK": "CN", "SZX": "CN",
+}
+
+// 同时是常见英文单词的代码，仅在名称开头或分隔符、国旗之后识别，避免"IPLC IN 01"被识别为印度
+var ambiguousRegionCodes = map[string]bool{"IN": true, "ID": true, "IT": true}
+
+var (
+	chineseKeywords []regionKeyword
+	englishKeywords []regionKeyword
+	regionTokenRe   = regexp.MustCompile(`[A-Za-z]+`)
+	ignoredTokenRe  = regexp.MustCompile(`(?i)^(CN2|AS\d+)$`)
+	alnumTokenRe    = regexp.MustCompile(`[A-Za-z0-9]+`)
+)
+
+func init() {
+	chinese := make(map[string]string)
+	english := make(map[string]string)
+	for _, country := range countries {
+		chinese[country.Name] = country.Code
+		english[strings.ToLower(country.EnName)] = country.Code
+	}
+	for keyword, code := range chineseRegionAliases {
+		chinese[keyword] = code
+	}
+	for keyword, code := range englishRegionAliases {
+		english[keyword] = code
+	}
+
+	chineseKeywords = sortRegionKeywords(chinese)
+	englishKeywords = sortRegionKeywords(english)
+}
+
+// sortRegionKeywords 按关键词长度从长到短排序，使"中国香港"等优先匹配更具体的地区
+func sortRegionKeywords(keywords map[string]string) []regionKeyword {
+	list := make([]regionKeyword, 0, len(keywords))
+	for keyword, code := range keywords {
+		list = append(list, regionKeyword{keyword: keyword, code: code})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		// "中国"放在最后，避免"中国香港"等被识别为中国
+		if isChinaName(list[i].keyword) != isChinaName(list[j].keyword) {
+			return isChinaName(list[j].keyword)
+		}
+		li, lj := len([]rune(list[i].keyword)), len([]rune(list[j].keyword))
+		if li != lj {
+			return li > lj
+		}
+		return list[i].keyword < list[j].keyword
+	})
+	return list
+}
+
+// isChinaName 是否为中国的国家名称
+func isChinaName(keyword string) bool {
+	return keyword == "中国" || keyword == "china"
+}
+
+// InferCountry 根据节点名称推断国家代码，依次识别国旗、中文地名、英文地名与代码缩写，无法识别时返回空字符串
+func InferCountry(name string) string {
+	if code := countryFromFlag(name); code != "" {
+		return code
+	}
+
+	for _, item := range chineseKeywords {
+		if strings.Contains(name, item.keyword) {
+			return item.code
+		}
+	}
+
+	lower := " " + strings.Join(regionTokenRe.FindAllString(strings.ToLower(name), -1), " ") + " "
+	for _, item := range englishKeywords {
+		if strings.Contains(lower, " "+item.keyword+" ") {
+			return item.code
+		}
+	}
+
+	// 代码需为大写，并去掉HK01中的编号；CN2、AS4809等线路与ASN标识不参与匹配
+	for _, loc := range alnumTokenRe.FindAllStringIndex(name, -1) {
+		token := name[loc[0]:loc[1]]
+		if ignoredTokenRe.MatchString(token) {
+			continue
+		}
+		token = strings.TrimRight(token, "0123456789")
+		if ambiguousRegionCodes[token] && !afterSeparator(name[:loc[0]]) {
+			continue
+		}
+		if code, ok := regionCodes[token]; ok {
+			return code
+		}
+	}
+
+	return ""
+}
+
+// afterSeparator 判断前缀是否为空，或去掉末尾空白后以标点、符号或国旗结尾
+func afterSeparator(prefix string) bool {
+	prefix = strings.TrimRightFunc(prefix, unicode.IsSpace)
+	if prefix == "" {
+		return true
+	}
+	last, _ := utf8.DecodeLastRuneInString(prefix)
+	return unicode.IsPunct(last) || unicode.IsSymbol(last)
+}
+
+// countryFromFlag 从国旗emoji中识别国家代码
+func countryFromFlag(name string) string {
+	runes := []rune(name)
+	for i := 0; i+1 < len(runes); i++ {
+		if isRegionalIndicator(runes[i]) && isRegionalIndicator(runes[i+1]) {
+			code := string([]rune{runes[i] - 0x1F1E6 + 'A', runes[i+1] - 0x1F1E6 + 'A'})
+			if _, ok := LookupCountry(code); ok {
+				return code
+			}
+			i++
+		}
+	}
+	return ""
+}
+
+// isRegionalIndicator 判断是否为区域指示符号
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
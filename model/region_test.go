@@ -0,0 +1,61 @@
+package model
+
+import "testing"
+
+// TestInferCountry 常见节点名称的国家识别
+func TestInferCountry(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		// 国旗优先
+		{"🇯🇵 日本 01", "JP"},
+		{"🇺🇸 香港中转 美国", "US"},
+		{"🇭🇰|IPLC|01", "HK"},
+
+		// 中文地名，更具体的地名优先
+		{"中国香港 IEPL 01", "HK"},
+		{"中国台湾 HiNet", "TW"},
+		{"中国 上海 移动", "CN"},
+		{"美国马里兰 02", "US"},
+		{"马里兰 x2", "US"},
+		{"香港 01 | 原生", "HK"},
+		{"新加坡 Premium", "SG"},
+		{"韓國 首爾", "KR"},
+
+		// 英文地名
+		{"Hong Kong 01", "HK"},
+		{"Japan Tokyo", "JP"},
+		{"US Los Angeles 03", "US"},
+		{"Georgia Atlanta", "US"},
+		{"Atlanta 1Gbps", "US"},
+		{"United Kingdom London", "GB"},
+
+		// 代码与缩写
+		{"HK01", "HK"},
+		{"IPLC HK 01", "HK"},
+		{"[SG] Premium", "SG"},
+		{"UK-London 01", "GB"},
+		{"USA 10x", "US"},
+		{"NRT 02", "JP"},
+		{"CN2 GIA LAX", "US"},
+		{"AS4809 JP", "JP"},
+
+		// 同时是英文单词的代码
+		{"IPLC IN 01", ""},
+		{"IN 01", "IN"},
+		{"IPLC-IN 01", "IN"},
+		{"🇮🇳 IN 01", "IN"},
+		{"Get IT now", ""},
+
+		// 无法识别
+		{"Premium 01", ""},
+		{"hk 01", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := InferCountry(test.name); got != test.want {
+			t.Errorf("InferCountry(%q) = %q，期望 %q", test.name, got, test.want)
+		}
+	}
+}
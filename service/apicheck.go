@@ -2,41 +2,22 @@ package service
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"net"
 	"net/http"
-	"net/url"
 	"strings"
 	"time"
 
 	"github.com/nariahlamb/sharesubweb/config"
 	"github.com/nariahlamb/sharesubweb/model"
-	"golang.org/x/net/proxy"
 )
 
 // APICheckService API检测服务
 type APICheckService struct {
-	cfg *config.Config
-}
-
-// IPLookupResponse IP查询响应
-type IPLookupResponse struct {
-	Country     string  `json:"country"`
-	CountryCode string  `json:"countryCode"`
-	Region      string  `json:"region"`
-	RegionName  string  `json:"regionName"`
-	City        string  `json:"city"`
-	Zip         string  `json:"zip"`
-	Lat         float64 `json:"lat"`
-	Lon         float64 `json:"lon"`
-	Timezone    string  `json:"timezone"`
-	ISP         string  `json:"isp"`
-	Org         string  `json:"org"`
-	AS          string  `json:"as"`
-	Query       string  `json:"query"`
+	cfg         *config.Config
+	proxyTester *ProxyTester
 }
 
 // OpenAIResponse OpenAI API响应
@@ -72,95 +53,144 @@ type Usage struct {
 // NewAPICheckService 创建API检查服务
 func NewAPICheckService(cfg *config.Config) *APICheckService {
 	return &APICheckService{
-		cfg: cfg,
+		cfg:         cfg,
+		proxyTester: NewProxyTester(cfg.NodeCheck.Timeout, cfg.NodeCheck.LocalPort),
 	}
 }
 
+func init() {
+	// 注册内置检测器
+	RegisterChecker(NewCheckerFunc("YouTube", "Youtube", checkYouTube))
+}
+
 // CheckProxyNode 检查代理节点的API连通性
 func (s *APICheckService) CheckProxyNode(node *model.ProxyNode) {
-	// 初始化API连通性映射
-	if node.APIConnectivity == nil {
-		node.APIConnectivity = make(map[string]bool)
+	// 清空上一轮结果，已禁用的检测项不再保留旧结果
+	node.APIResults = make(map[string]*model.APIResult)
+
+	// 创建代理HTTP客户端，所有检测项共用，并记录最后一次请求的状态
+	client, clientErr := s.proxyTester.CreateProxyHTTPClient(node)
+	var recorder *recordingTransport
+	if clientErr == nil {
+		recorder = &recordingTransport{base: client.Transport}
+		client.Transport = recorder
+	} else {
+		fmt.Printf("节点 %s 创建代理客户端失败: %v\n", node.Name, clientErr)
 	}
-	
+
 	// 根据配置确定要检测的API
 	for _, api := range s.cfg.NodeCheck.API.List {
 		if !api.Enable {
 			continue
 		}
-		
-		// 根据API名称执行不同的检测
-		switch api.Name {
-		case "OpenAI":
-			node.APIConnectivity["OpenAI"] = s.CheckOpenAI(node)
-		case "Gemini":
-			node.APIConnectivity["Gemini"] = s.CheckGemini(node)
-		case "YouTube":
-			node.APIConnectivity["YouTube"] = s.CheckYouTube(node)
-		case "Netflix":
-			node.APIConnectivity["Netflix"] = s.CheckNetflix(node)
+
+		checker, err := s.resolveChecker(api)
+		if err != nil {
+			fmt.Printf("API检测项 %s 无效: %v\n", api.Name, err)
+			continue
+		}
+
+		tag := checker.Tag()
+		if api.Tag != "" {
+			tag = api.Tag
 		}
+
+		// 无法创建代理客户端时检测项记为不可用，不保留为空
+		if clientErr != nil {
+			node.SetAPIResult(api.Name, &model.APIResult{
+				Status:     model.UnlockBlocked,
+				Tag:        tag,
+				ErrorClass: "proxy",
+				CheckedAt:  time.Now(),
+			})
+			continue
+		}
+
+		// 超时按检测项配置
+		client.Timeout = s.itemTimeout(api)
+		ctx := &CheckContext{
+			Client: client,
+			Node:   node,
+			Config: s.cfg,
+			Item:   api,
+		}
+
+		result := s.runCheck(checker, ctx, recorder)
+		result.Tag = tag
+		node.SetAPIResult(api.Name, result)
 	}
-	
-	// 检查IP信息
-	s.CheckIPInfo(node)
 }
 
-// 创建基于节点的HTTP客户端
-func (s *APICheckService) createProxyHTTPClient(node *model.ProxyNode) (*http.Client, error) {
-	// 设置代理地址
-	var proxyURL string
-	
-	switch node.Type {
-	case "ss":
-		// 对SS节点，使用本地启动的socks代理
-		// 这里假设已经有本地服务将SS节点转为socks代理
-		proxyURL = fmt.Sprintf("socks5://127.0.0.1:%d", s.cfg.NodeCheck.LocalPort)
-	case "vmess", "trojan", "vless":
-		// 对于其他类型节点，同样使用本地服务转发的socks代理
-		proxyURL = fmt.Sprintf("socks5://127.0.0.1:%d", s.cfg.NodeCheck.LocalPort)
-	default:
-		return nil, fmt.Errorf("不支持的节点类型: %s", node.Type)
+// runCheck 执行检测项，仅在网络错误时按配置重试，地区限制等明确结果不再重试
+func (s *APICheckService) runCheck(checker UnlockChecker, ctx *CheckContext, recorder *recordingTransport) *model.APIResult {
+	var result CheckResult
+	var errorClass string
+	var latency time.Duration
+	for i := 0; i < s.itemRetryCount(ctx.Item); i++ {
+		recorder.reset()
+		start := time.Now()
+		result = checker.Check(ctx)
+		latency = time.Since(start)
+		errorClass = classifyError(recorder.lastErr)
+		if result.Status.Available() || errorClass == "" {
+			break
+		}
 	}
-	
-	// 解析代理URL
-	parsedURL, err := url.Parse(proxyURL)
-	if err != nil {
-		return nil, err
+
+	return &model.APIResult{
+		Status:     result.Status,
+		HTTPCode:   recorder.lastStatus,
+		Latency:    int(latency.Milliseconds()),
+		Region:     result.Region,
+		Reason:     result.Reason,
+		ErrorClass: errorClass,
+		CheckedAt:  time.Now(),
 	}
-	
-	// 创建socks5代理拨号器
-	dialer, err := proxy.FromURL(parsedURL, proxy.Direct)
-	if err != nil {
-		return nil, err
+}
+
+// itemTimeout 获取检测项的超时时间，依次使用检测项、API检测、节点检测的配置
+func (s *APICheckService) itemTimeout(api config.APIItemConfig) time.Duration {
+	timeout := api.Timeout
+	if timeout <= 0 {
+		timeout = s.cfg.NodeCheck.API.Timeout
 	}
-	
-	// 创建自定义传输
-	transport := &http.Transport{
-		Dial: dialer.Dial,
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			return dialer.(proxy.ContextDialer).DialContext(ctx, network, addr)
-		},
-		TLSHandshakeTimeout: 10 * time.Second,
+	if timeout <= 0 {
+		timeout = s.cfg.NodeCheck.Timeout
 	}
-	
-	// 创建HTTP客户端
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   time.Duration(s.cfg.NodeCheck.API.Timeout) * time.Second,
+	if timeout <= 0 {
+		timeout = 10 // 默认10秒
 	}
-	
-	return client, nil
+	return time.Duration(timeout) * time.Second
 }
 
-// CheckOpenAI 检测OpenAI API可用性
-func (s *APICheckService) CheckOpenAI(node *model.ProxyNode) bool {
-	// 创建代理HTTP客户端
-	client, err := s.createProxyHTTPClient(node)
-	if err != nil {
-		return false
+// itemRetryCount 获取检测项的尝试次数，至少为1次
+func (s *APICheckService) itemRetryCount(api config.APIItemConfig) int {
+	retryCount := api.RetryCount
+	if retryCount <= 0 {
+		retryCount = s.cfg.NodeCheck.API.RetryCount
 	}
-	
+	if retryCount <= 0 {
+		retryCount = 1
+	}
+	return retryCount
+}
+
+// resolveChecker 为API项选择检测器：配置了规则的使用规则检测器，否则使用已注册的同名检测器，
+// 都没有时按URL的状态码进行检测
+func (s *APICheckService) resolveChecker(api config.APIItemConfig) (UnlockChecker, error) {
+	if len(api.Rules) > 0 {
+		return NewRuleChecker(api)
+	}
+	if checker, ok := GetChecker(api.Name); ok {
+		return checker, nil
+	}
+	return NewRuleChecker(api)
+}
+
+// openAIChatCompletion 使用配置的密钥发起一次对话请求，会产生API费用
+func openAIChatCompletion(ctx *CheckContext) bool {
+	client := ctx.Client
+
 	// 准备请求体
 	requestBody := map[string]interface{}{
 		"model": "gpt-3.5-turbo",
@@ -186,7 +216,7 @@ func (s *APICheckService) CheckOpenAI(node *model.ProxyNode) bool {
 	
 	// 设置请求头
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.cfg.NodeCheck.API.OpenAIKey))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ctx.Config.NodeCheck.API.OpenAIKey))
 	
 	// 发送请求
 	resp, err := client.Do(req)
@@ -201,7 +231,7 @@ func (s *APICheckService) CheckOpenAI(node *model.ProxyNode) bool {
 	}
 	
 	// 读取响应体
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxCheckBodySize))
 	if err != nil {
 		return false
 	}
@@ -216,16 +246,12 @@ func (s *APICheckService) CheckOpenAI(node *model.ProxyNode) bool {
 	return len(openAIResp.Choices) > 0 && openAIResp.Choices[0].Message.Content != ""
 }
 
-// CheckGemini 检测Google Gemini API可用性
-func (s *APICheckService) CheckGemini(node *model.ProxyNode) bool {
-	// 创建代理HTTP客户端
-	client, err := s.createProxyHTTPClient(node)
-	if err != nil {
-		return false
-	}
-	
+// geminiGenerateContent 使用配置的密钥发起一次生成请求，会消耗API配额
+func geminiGenerateContent(ctx *CheckContext) bool {
+	client := ctx.Client
+
 	// 构建API URL
-	apiKey := s.cfg.NodeCheck.API.GeminiKey
+	apiKey := ctx.Config.NodeCheck.API.GeminiKey
 	if apiKey == "" {
 		return false
 	}
@@ -273,14 +299,18 @@ func (s *APICheckService) CheckGemini(node *model.ProxyNode) bool {
 	return resp.StatusCode == http.StatusOK
 }
 
-// CheckYouTube 检测YouTube可用性
-func (s *APICheckService) CheckYouTube(node *model.ProxyNode) bool {
-	// 创建代理HTTP客户端
-	client, err := s.createProxyHTTPClient(node)
-	if err != nil {
-		return false
+// checkYouTube 检测YouTube可用性
+func checkYouTube(ctx *CheckContext) CheckResult {
+	if youTubeReachable(ctx) {
+		return CheckResult{Status: model.UnlockUnlocked}
 	}
-	
+	return CheckResult{Status: model.UnlockBlocked}
+}
+
+// youTubeReachable 检查YouTube首页能否正常打开
+func youTubeReachable(ctx *CheckContext) bool {
+	client := ctx.Client
+
 	// 创建请求
 	req, err := http.NewRequest("GET", "https://www.youtube.com/", nil)
 	if err != nil {
@@ -300,7 +330,7 @@ func (s *APICheckService) CheckYouTube(node *model.ProxyNode) bool {
 	}
 	
 	// 读取响应体
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxCheckBodySize))
 	if err != nil {
 		return false
 	}
@@ -309,86 +339,3 @@ func (s *APICheckService) CheckYouTube(node *model.ProxyNode) bool {
 	content := string(body)
 	return strings.Contains(content, "YouTube") || strings.Contains(content, "youtube")
 }
-
-// CheckNetflix 检测Netflix可用性
-func (s *APICheckService) CheckNetflix(node *model.ProxyNode) bool {
-	// 创建代理HTTP客户端
-	client, err := s.createProxyHTTPClient(node)
-	if err != nil {
-		return false
-	}
-	
-	// 创建请求
-	req, err := http.NewRequest("GET", "https://www.netflix.com/title/80018499", nil)
-	if err != nil {
-		return false
-	}
-	
-	// 设置请求头
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
-	
-	// 发送请求
-	resp, err := client.Do(req)
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
-	
-	// 检查状态码不等于403即有效
-	// 对于Netflix，如果不可用会返回403，可用会返回200
-	return resp.StatusCode != http.StatusForbidden
-}
-
-// CheckIPInfo 检查节点出口IP信息
-func (s *APICheckService) CheckIPInfo(node *model.ProxyNode) {
-	// 创建代理HTTP客户端
-	client, err := s.createProxyHTTPClient(node)
-	if err != nil {
-		return
-	}
-	
-	// 创建请求
-	req, err := http.NewRequest("GET", "http://ip-api.com/json/", nil)
-	if err != nil {
-		return
-	}
-	
-	// 发送请求
-	resp, err := client.Do(req)
-	if err != nil {
-		return
-	}
-	defer resp.Body.Close()
-	
-	// 检查响应状态
-	if resp.StatusCode != http.StatusOK {
-		return
-	}
-	
-	// 读取响应体
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return
-	}
-	
-	// 解析响应
-	var ipResp IPLookupResponse
-	if err := json.Unmarshal(body, &ipResp); err != nil {
-		return
-	}
-	
-	// 设置节点IP信息
-	node.OutletIP = ipResp.Query
-	node.IPInfo = &model.IPInfo{
-		Country:     ipResp.Country,
-		CountryCode: ipResp.CountryCode,
-		Region:      ipResp.RegionName,
-		City:        ipResp.City,
-		ISP:         ipResp.ISP,
-		ASN:         ipResp.AS,
-		Org:         ipResp.Org,
-		Lat:         ipResp.Lat,
-		Lon:         ipResp.Lon,
-		TimeZone:    ipResp.Timezone,
-	}
-} 
\ No newline at end of file
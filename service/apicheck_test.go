@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// TestClassifyError 网络错误按类型归类
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{&url.Error{Op: "Get", URL: "https://example.com", Err: context.DeadlineExceeded}, "timeout"},
+		{&net.DNSError{Err: "no such host", Name: "example.com"}, "dns"},
+		{&tls.CertificateVerificationError{Err: errors.New("x509: certificate signed by unknown authority")}, "tls"},
+		{tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}, "tls"},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}}, "refused"},
+		{&net.OpError{Op: "read", Net: "tcp", Err: &os.SyscallError{Syscall: "read", Err: syscall.ECONNRESET}}, "reset"},
+		{fmt.Errorf("读取响应失败: %w", io.EOF), "reset"},
+		{errors.New("socks connect tcp 127.0.0.1:1080->example.com:443: unknown error general SOCKS server failure"), "proxy"},
+		{errors.New("proxyconnect tcp: dial tcp 127.0.0.1:7890: i/o error"), "proxy"},
+		{errors.New("remote error: tls: handshake failure"), "tls"},
+		{errors.New("unexpected error"), "network"},
+	}
+	for _, test := range tests {
+		if got := classifyError(test.err); got != test.want {
+			t.Errorf("classifyError(%v) = %q，期望 %q", test.err, got, test.want)
+		}
+	}
+}
+
+// flakyTransport 前failures次请求返回连接被拒绝，之后转发到base
+type flakyTransport struct {
+	base     http.RoundTripper
+	failures int
+}
+
+func (t *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.failures > 0 {
+		t.failures--
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// TestRunCheckRetry 仅在网络错误时重试，明确的检测结果不再重试
+func TestRunCheckRetry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/blocked" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		path       string
+		failures   int
+		wantCalls  int
+		wantStatus model.UnlockStatus
+		wantCode   int
+		wantClass  string
+	}{
+		{"网络错误后重试成功", "/", 2, 3, model.UnlockUnlocked, http.StatusOK, ""},
+		{"重试次数用完仍为网络错误", "/", 5, 3, model.UnlockBlocked, 0, "refused"},
+		{"地区限制不重试", "/blocked", 0, 1, model.UnlockBlocked, http.StatusForbidden, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			item := config.APIItemConfig{Name: "Test", URL: server.URL + test.path, RetryCount: 3}
+			ruleChecker, err := NewRuleChecker(item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			calls := 0
+			checker := NewCheckerFunc("Test", "T", func(ctx *CheckContext) CheckResult {
+				calls++
+				return ruleChecker.Check(ctx)
+			})
+
+			recorder := &recordingTransport{base: &flakyTransport{base: http.DefaultTransport, failures: test.failures}}
+			ctx := &CheckContext{Client: &http.Client{Transport: recorder}, Config: &config.Config{}, Item: item}
+			s := &APICheckService{cfg: ctx.Config}
+
+			result := s.runCheck(checker, ctx, recorder)
+			if calls != test.wantCalls {
+				t.Errorf("检测次数为 %d，期望 %d", calls, test.wantCalls)
+			}
+			if result.Status != test.wantStatus || result.HTTPCode != test.wantCode || result.ErrorClass != test.wantClass {
+				t.Errorf("检测结果为 %+v", result)
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package service
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// UnlockChecker 解锁检测器
+type UnlockChecker interface {
+	// Name 检测器名称，与配置中API项的name对应
+	Name() string
+	// Tag 重命名时使用的短标签
+	Tag() string
+	// Check 通过节点代理执行检测
+	Check(ctx *CheckContext) CheckResult
+}
+
+// CheckContext 检测上下文
+type CheckContext struct {
+	Client *http.Client         // 经过节点代理的HTTP客户端
+	Node   *model.ProxyNode     // 待检测节点
+	Config *config.Config       // 全局配置
+	Item   config.APIItemConfig // 当前检测项配置
+}
+
+// CheckResult 检测结果
+type CheckResult struct {
+	Status model.UnlockStatus
+	Region string // 检测到的解锁地区代码，如JP、US
+	Reason string // 结果原因代码，如unsupported_country、cloudflare_block
+}
+
+// maxCheckBodySize 检测响应读取的大小上限，超出部分不参与匹配
+const maxCheckBodySize = 4 << 20
+
+var (
+	checkerRegistry = make(map[string]UnlockChecker)
+	checkerMutex    sync.RWMutex
+)
+
+// RegisterChecker 注册解锁检测器，同名检测器会被覆盖
+func RegisterChecker(checker UnlockChecker) {
+	checkerMutex.Lock()
+	defer checkerMutex.Unlock()
+
+	checkerRegistry[checker.Name()] = checker
+}
+
+// GetChecker 根据名称获取解锁检测器
+func GetChecker(name string) (UnlockChecker, bool) {
+	checkerMutex.RLock()
+	defer checkerMutex.RUnlock()
+
+	checker, ok := checkerRegistry[name]
+	return checker, ok
+}
+
+// ListCheckers 获取所有已注册的检测器，按名称排序
+func ListCheckers() []UnlockChecker {
+	checkerMutex.RLock()
+	defer checkerMutex.RUnlock()
+
+	checkers := make([]UnlockChecker, 0, len(checkerRegistry))
+	for _, checker := range checkerRegistry {
+		checkers = append(checkers, checker)
+	}
+	sort.Slice(checkers, func(i, j int) bool {
+		return checkers[i].Name() < checkers[j].Name()
+	})
+	return checkers
+}
+
+// checkerFunc 以函数形式实现的检测器
+type checkerFunc struct {
+	name string
+	tag  string
+	fn   func(ctx *CheckContext) CheckResult
+}
+
+func (c *checkerFunc) Name() string                        { return c.name }
+func (c *checkerFunc) Tag() string                         { return c.tag }
+func (c *checkerFunc) Check(ctx *CheckContext) CheckResult { return c.fn(ctx) }
+
+// NewCheckerFunc 使用函数创建检测器
+func NewCheckerFunc(name, tag string, fn func(ctx *CheckContext) CheckResult) UnlockChecker {
+	return &checkerFunc{name: name, tag: tag, fn: fn}
+}
+
+// ruleChecker 基于配置规则的检测器
+type ruleChecker struct {
+	item  config.APIItemConfig
+	rules []compiledRule
+}
+
+// compiledRule 预编译的匹配规则
+type compiledRule struct {
+	status   []int
+	body     *regexp.Regexp
+	header   map[string]*regexp.Regexp
+	redirect *regexp.Regexp
+	result   model.UnlockStatus
+}
+
+// NewRuleChecker 根据API项配置创建规则检测器
+// 未配置规则时，状态码在200-399之间即视为解锁
+func NewRuleChecker(item config.APIItemConfig) (UnlockChecker, error) {
+	if item.URL == "" {
+		return nil, fmt.Errorf("API项 %s 未配置URL", item.Name)
+	}
+
+	checker := &ruleChecker{item: item}
+	for i, rule := range item.Rules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("API项 %s 的第%d条规则无效: %v", item.Name, i+1, err)
+		}
+		checker.rules = append(checker.rules, compiled)
+	}
+
+	return checker, nil
+}
+
+// compileRule 编译单条匹配规则
+func compileRule(rule config.APIMatchRule) (compiledRule, error) {
+	compiled := compiledRule{
+		status: rule.Status,
+		header: make(map[string]*regexp.Regexp),
+		result: model.UnlockUnlocked,
+	}
+
+	if rule.Result != "" {
+		result, err := ParseUnlockStatus(rule.Result)
+		if err != nil {
+			return compiled, err
+		}
+		compiled.result = result
+	}
+
+	var err error
+	if rule.BodyRegex != "" {
+		if compiled.body, err = regexp.Compile(rule.BodyRegex); err != nil {
+			return compiled, err
+		}
+	}
+	for name, pattern := range rule.Header {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return compiled, err
+		}
+		compiled.header[name] = re
+	}
+	if rule.Redirect != "" {
+		if compiled.redirect, err = regexp.Compile(rule.Redirect); err != nil {
+			return compiled, err
+		}
+	}
+
+	return compiled, nil
+}
+
+// ParseUnlockStatus 解析结果类型名称
+func ParseUnlockStatus(value string) (model.UnlockStatus, error) {
+	switch status := model.UnlockStatus(strings.ToLower(value)); status {
+	case model.UnlockUnlocked, model.UnlockBlocked, model.UnlockRegionOnly, model.UnlockOriginalsOnly:
+		return status, nil
+	default:
+		return "", fmt.Errorf("未知的结果类型: %s", value)
+	}
+}
+
+func (c *ruleChecker) Name() string { return c.item.Name }
+
+func (c *ruleChecker) Tag() string {
+	if c.item.Tag != "" {
+		return c.item.Tag
+	}
+	return c.item.Name
+}
+
+// Check 发送请求并依次匹配规则
+func (c *ruleChecker) Check(ctx *CheckContext) CheckResult {
+	method := c.item.Method
+	if method == "" {
+		method = "GET"
+	}
+
+	req, err := http.NewRequest(method, c.item.URL, nil)
+	if err != nil {
+		return CheckResult{Status: model.UnlockBlocked}
+	}
+	for key, value := range c.item.Headers {
+		req.Header.Set(key, value)
+	}
+
+	// 存在重定向条件时不跟随重定向，以便读取Location
+	client := ctx.Client
+	if c.needsRedirect() {
+		noFollow := *client
+		noFollow.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+		client = &noFollow
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return CheckResult{Status: model.UnlockBlocked}
+	}
+	defer resp.Body.Close()
+
+	// 未配置规则时沿用状态码判断
+	if len(c.rules) == 0 {
+		if resp.StatusCode >= 200 && resp.StatusCode < 400 {
+			return CheckResult{Status: model.UnlockUnlocked}
+		}
+		return CheckResult{Status: model.UnlockBlocked}
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxCheckBodySize))
+	if err != nil {
+		return CheckResult{Status: model.UnlockBlocked}
+	}
+
+	for _, rule := range c.rules {
+		if rule.match(resp, body) {
+			return CheckResult{Status: rule.result}
+		}
+	}
+
+	return CheckResult{Status: model.UnlockBlocked}
+}
+
+// needsRedirect 是否存在重定向条件
+func (c *ruleChecker) needsRedirect() bool {
+	for _, rule := range c.rules {
+		if rule.redirect != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// match 判断响应是否满足规则的全部条件
+func (r compiledRule) match(resp *http.Response, body []byte) bool {
+	if len(r.status) > 0 {
+		matched := false
+		for _, code := range r.status {
+			if resp.StatusCode == code {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if r.body != nil && !r.body.Match(body) {
+		return false
+	}
+
+	for name, re := range r.header {
+		if !re.MatchString(resp.Header.Get(name)) {
+			return false
+		}
+	}
+
+	if r.redirect != nil && !r.redirect.MatchString(resp.Header.Get("Location")) {
+		return false
+	}
+
+	return true
+}
+
+// recordingTransport 记录最后一次请求的状态码与错误，用于生成检测详情
+type recordingTransport struct {
+	base       http.RoundTripper
+	lastStatus int
+	lastErr    error
+}
+
+// RoundTrip 转发请求并记录结果
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.lastErr = err
+		return nil, err
+	}
+	t.lastStatus = resp.StatusCode
+	t.lastErr = nil
+	return resp, nil
+}
+
+// reset 清空记录
+func (t *recordingTransport) reset() {
+	t.lastStatus = 0
+	t.lastErr = nil
+}
+
+// classifyError 将网络错误归类，无错误时返回空字符串
+func classifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return "dns"
+	}
+
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &certErr) || errors.As(err, &recordErr) {
+		return "tls"
+	}
+
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "reset"
+	}
+
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "socks connect"), strings.Contains(message, "proxyconnect"):
+		return "proxy"
+	case strings.Contains(message, "tls:"), strings.Contains(message, "x509:"):
+		return "tls"
+	}
+
+	return "network"
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// 检测结果原因代码
+const (
+	ReasonOK                 = "ok"                  // 可用
+	ReasonUnsupportedCountry = "unsupported_country" // 所在地区不受支持
+	ReasonDisallowedISP      = "disallowed_isp"      // 出口ISP被禁止（常见于机房IP）
+	ReasonCloudflareBlock    = "cloudflare_block"    // 被Cloudflare拦截
+	ReasonNetworkError       = "network_error"       // 请求失败
+	ReasonUnexpected         = "unexpected_response" // 无法识别的响应
+	ReasonPaidOK             = "paid_ok"             // 付费请求成功
+	ReasonPaidFailed         = "paid_failed"         // 付费请求失败
+)
+
+func init() {
+	// 注册AI服务检测器，默认不使用任何密钥
+	RegisterChecker(NewCheckerFunc("OpenAI", "Openai", checkOpenAI))
+	RegisterChecker(NewCheckerFunc("ChatGPT", "GPT", checkChatGPT))
+	RegisterChecker(NewCheckerFunc("Claude", "Claude", checkClaude))
+	RegisterChecker(NewCheckerFunc("Gemini", "Gemini", checkGemini))
+	RegisterChecker(NewCheckerFunc("Copilot", "Copilot", checkCopilot))
+	RegisterChecker(NewCheckerFunc("Perplexity", "PPLX", checkPerplexity))
+}
+
+// blocked 生成不可用结果
+func blocked(reason string) CheckResult {
+	return CheckResult{Status: model.UnlockBlocked, Reason: reason}
+}
+
+// unlocked 生成可用结果
+func unlocked() CheckResult {
+	return CheckResult{Status: model.UnlockUnlocked, Reason: ReasonOK}
+}
+
+// isCloudflareBlock 判断响应是否为Cloudflare的拦截或人机验证页
+func isCloudflareBlock(resp *http.Response, body []byte) bool {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusServiceUnavailable {
+		return false
+	}
+	if !strings.EqualFold(resp.Header.Get("Server"), "cloudflare") && resp.Header.Get("Cf-Ray") == "" {
+		return false
+	}
+	for _, marker := range []string{"cf-chl", "Just a moment", "Attention Required", "error code: 1020", "cf-error-details"} {
+		if bytes.Contains(body, []byte(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOpenAI 检测OpenAI API可用性
+// 不带密钥请求models接口：受限地区返回unsupported_country_region_territory，其余返回未授权
+func checkOpenAI(ctx *CheckContext) CheckResult {
+	resp, body, err := fetchPage(ctx.Client, "GET", "https://api.openai.com/v1/models", nil, nil)
+	if err != nil {
+		return blocked(ReasonNetworkError)
+	}
+
+	switch {
+	case bytes.Contains(body, []byte("unsupported_country")):
+		return blocked(ReasonUnsupportedCountry)
+	case isCloudflareBlock(resp, body):
+		return blocked(ReasonCloudflareBlock)
+	case resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusOK:
+		return blocked(ReasonUnexpected)
+	}
+
+	// 显式开启付费检测且配置了密钥时，再发起一次真实请求
+	if ctx.Config.NodeCheck.API.PaidProbe && ctx.Config.NodeCheck.API.OpenAIKey != "" {
+		if !openAIChatCompletion(ctx) {
+			return blocked(ReasonPaidFailed)
+		}
+		return CheckResult{Status: model.UnlockUnlocked, Reason: ReasonPaidOK}
+	}
+
+	return unlocked()
+}
+
+// checkChatGPT 检测ChatGPT网页与客户端可用性
+func checkChatGPT(ctx *CheckContext) CheckResult {
+	resp, body, err := fetchPage(ctx.Client, "GET", "https://ios.chat.openai.com/", nil, nil)
+	if err != nil {
+		return blocked(ReasonNetworkError)
+	}
+
+	switch {
+	case bytes.Contains(body, []byte("unsupported_country")):
+		return blocked(ReasonUnsupportedCountry)
+	case bytes.Contains(body, []byte("disallowed ISP")):
+		return blocked(ReasonDisallowedISP)
+	case bytes.Contains(body, []byte("Request is not allowed")):
+		// 正常地区返回的提示，说明请求已到达后端
+		return unlocked()
+	case isCloudflareBlock(resp, body):
+		return blocked(ReasonCloudflareBlock)
+	}
+
+	return blocked(ReasonUnexpected)
+}
+
+// checkClaude 检测Claude可用性，受限地区会被重定向到app-unavailable-in-region
+func checkClaude(ctx *CheckContext) CheckResult {
+	resp, body, err := fetchPage(ctx.Client, "GET", "https://claude.ai/", nil, nil)
+	if err != nil {
+		return blocked(ReasonNetworkError)
+	}
+
+	switch {
+	case strings.Contains(resp.Request.URL.String(), "app-unavailable-in-region"):
+		return blocked(ReasonUnsupportedCountry)
+	case isCloudflareBlock(resp, body):
+		return blocked(ReasonCloudflareBlock)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return blocked(ReasonUnexpected)
+	}
+
+	return unlocked()
+}
+
+// checkGemini 检测Gemini可用性
+// 网页中包含45631641,null,true标记时表示当前地区可用
+func checkGemini(ctx *CheckContext) CheckResult {
+	resp, body, err := fetchPage(ctx.Client, "GET", "https://gemini.google.com/", nil, nil)
+	if err != nil {
+		return blocked(ReasonNetworkError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return blocked(ReasonUnexpected)
+	}
+	if !bytes.Contains(body, []byte("45631641,null,true")) {
+		return blocked(ReasonUnsupportedCountry)
+	}
+
+	// 显式开启付费检测且配置了密钥时，再发起一次真实请求
+	if ctx.Config.NodeCheck.API.PaidProbe && ctx.Config.NodeCheck.API.GeminiKey != "" {
+		if !geminiGenerateContent(ctx) {
+			return blocked(ReasonPaidFailed)
+		}
+		return CheckResult{Status: model.UnlockUnlocked, Reason: ReasonPaidOK}
+	}
+
+	return unlocked()
+}
+
+// checkCopilot 检测Microsoft Copilot可用性
+func checkCopilot(ctx *CheckContext) CheckResult {
+	resp, body, err := fetchPage(ctx.Client, "GET", "https://copilot.microsoft.com/", nil, nil)
+	if err != nil {
+		return blocked(ReasonNetworkError)
+	}
+
+	switch {
+	case bytes.Contains(body, []byte("not available in your region")):
+		return blocked(ReasonUnsupportedCountry)
+	case isCloudflareBlock(resp, body):
+		return blocked(ReasonCloudflareBlock)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return blocked(ReasonUnexpected)
+	}
+
+	return unlocked()
+}
+
+// checkPerplexity 检测Perplexity可用性，主要受Cloudflare风控影响
+func checkPerplexity(ctx *CheckContext) CheckResult {
+	resp, body, err := fetchPage(ctx.Client, "GET", "https://www.perplexity.ai/", nil, nil)
+	if err != nil {
+		return blocked(ReasonNetworkError)
+	}
+
+	switch {
+	case isCloudflareBlock(resp, body):
+		return blocked(ReasonCloudflareBlock)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return blocked(ReasonUnexpected)
+	}
+
+	return unlocked()
+}
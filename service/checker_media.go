@@ -0,0 +1,264 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// browserUserAgent 流媒体检测使用的浏览器UA，部分站点会拦截非浏览器请求
+const browserUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+var (
+	netflixRegionRe   = regexp.MustCompile(`"requestCountry":\{"id":"([A-Z]{2})"`)
+	youTubeRegionRe   = regexp.MustCompile(`"(?:INNERTUBE_CONTEXT_GL|countryCode)":"([A-Z]{2})"`)
+	disneyRegionRe    = regexp.MustCompile(`"(?:region|countryCode)"\s*:\s*"([A-Za-z]{2})"`)
+	primeRegionRe     = regexp.MustCompile(`"currentTerritory":"([A-Z]{2})"`)
+	tikTokRegionRe    = regexp.MustCompile(`"region":"([A-Z]{2})"`)
+	netflixTitleIDs   = []string{"81280792", "70143836"} // 非自制剧，用于区分完整解锁与仅自制剧
+	netflixOriginalID = "80018499"                       // 自制剧
+)
+
+func init() {
+	// 注册流媒体检测器
+	RegisterChecker(NewCheckerFunc("Netflix", "Netflix", checkNetflix))
+	RegisterChecker(NewCheckerFunc("YouTubePremium", "YTP", checkYouTubePremium))
+	RegisterChecker(NewCheckerFunc("Disney+", "D+", checkDisneyPlus))
+	RegisterChecker(NewCheckerFunc("PrimeVideo", "PV", checkPrimeVideo))
+	RegisterChecker(NewCheckerFunc("Spotify", "SP", checkSpotify))
+	RegisterChecker(NewCheckerFunc("TikTok", "TK", checkTikTok))
+	RegisterChecker(NewCheckerFunc("BBCiPlayer", "BBC", checkBBCiPlayer))
+}
+
+// fetchPage 通过节点代理请求页面，返回响应和响应体
+func fetchPage(client *http.Client, method, pageURL string, body io.Reader, headers map[string]string) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(method, pageURL, body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.Header.Set("User-Agent", browserUserAgent)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxCheckBodySize))
+	if err != nil {
+		return resp, nil, err
+	}
+
+	return resp, data, nil
+}
+
+// matchRegion 从页面中提取地区代码
+func matchRegion(re *regexp.Regexp, body []byte) string {
+	if match := re.FindSubmatch(body); len(match) > 1 {
+		return strings.ToUpper(string(match[1]))
+	}
+	return ""
+}
+
+// checkNetflix 检测Netflix解锁情况
+// 非自制剧可以打开为完整解锁，仅自制剧可以打开为仅自制剧
+func checkNetflix(ctx *CheckContext) CheckResult {
+	for _, id := range netflixTitleIDs {
+		resp, body, err := fetchPage(ctx.Client, "GET", "https://www.netflix.com/title/"+id, nil, nil)
+		if err != nil {
+			return CheckResult{Status: model.UnlockBlocked}
+		}
+		if resp.StatusCode == http.StatusOK {
+			return CheckResult{
+				Status: model.UnlockUnlocked,
+				Region: netflixRegion(resp, body),
+			}
+		}
+		if resp.StatusCode == http.StatusForbidden {
+			return CheckResult{Status: model.UnlockBlocked}
+		}
+	}
+
+	resp, body, err := fetchPage(ctx.Client, "GET", "https://www.netflix.com/title/"+netflixOriginalID, nil, nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return CheckResult{Status: model.UnlockBlocked}
+	}
+
+	return CheckResult{
+		Status: model.UnlockOriginalsOnly,
+		Region: netflixRegion(resp, body),
+	}
+}
+
+// netflixRegion 获取Netflix地区，优先使用页面数据，其次使用重定向后的地区路径，无法识别时为空
+func netflixRegion(resp *http.Response, body []byte) string {
+	if region := matchRegion(netflixRegionRe, body); region != "" {
+		return region
+	}
+
+	// 形如 https://www.netflix.com/jp/title/xxx
+	parts := strings.Split(strings.Trim(resp.Request.URL.Path, "/"), "/")
+	if len(parts) > 1 && len(parts[0]) >= 2 && parts[1] == "title" {
+		return strings.ToUpper(parts[0][:2])
+	}
+	return ""
+}
+
+// checkYouTubePremium 检测YouTube Premium解锁情况
+func checkYouTubePremium(ctx *CheckContext) CheckResult {
+	resp, body, err := fetchPage(ctx.Client, "GET", "https://www.youtube.com/premium", nil, map[string]string{
+		"Cookie": "YSC=BiCUU3-5Gdk; CONSENT=YES+cb.20220301-11-p0.en+FX+700",
+	})
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return CheckResult{Status: model.UnlockBlocked}
+	}
+
+	content := string(body)
+	if strings.Contains(content, "www.google.cn") {
+		return CheckResult{Status: model.UnlockBlocked, Region: "CN"}
+	}
+	if strings.Contains(content, "Premium is not available in your country") {
+		return CheckResult{Status: model.UnlockBlocked, Region: matchRegion(youTubeRegionRe, body)}
+	}
+
+	// 页面中没有地区代码时保持为空，不猜测地区
+	region := matchRegion(youTubeRegionRe, body)
+	if strings.Contains(content, "ad-free") {
+		return CheckResult{Status: model.UnlockUnlocked, Region: region}
+	}
+
+	return CheckResult{Status: model.UnlockBlocked, Region: region}
+}
+
+// checkDisneyPlus 检测Disney+解锁情况
+func checkDisneyPlus(ctx *CheckContext) CheckResult {
+	resp, body, err := fetchPage(ctx.Client, "GET", "https://www.disneyplus.com/", nil, nil)
+	if err != nil || resp.StatusCode >= http.StatusBadRequest {
+		return CheckResult{Status: model.UnlockBlocked}
+	}
+
+	// 不支持的地区会被重定向到unavailable页面
+	finalURL := resp.Request.URL.String()
+	if strings.Contains(finalURL, "unavailable") {
+		return CheckResult{Status: model.UnlockBlocked}
+	}
+
+	region := matchRegion(disneyRegionRe, body)
+	if strings.Contains(finalURL, "preview") {
+		// 即将上线的地区只能访问预览页
+		return CheckResult{Status: model.UnlockRegionOnly, Region: region}
+	}
+
+	return CheckResult{Status: model.UnlockUnlocked, Region: region}
+}
+
+// checkPrimeVideo 检测Prime Video解锁情况
+func checkPrimeVideo(ctx *CheckContext) CheckResult {
+	resp, body, err := fetchPage(ctx.Client, "GET", "https://www.primevideo.com", nil, nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return CheckResult{Status: model.UnlockBlocked}
+	}
+
+	if strings.Contains(string(body), "isServiceRestricted") {
+		return CheckResult{Status: model.UnlockBlocked}
+	}
+
+	region := matchRegion(primeRegionRe, body)
+	if region == "" {
+		return CheckResult{Status: model.UnlockBlocked}
+	}
+
+	return CheckResult{Status: model.UnlockUnlocked, Region: region}
+}
+
+// spotifySignupResponse Spotify注册接口响应
+type spotifySignupResponse struct {
+	Status            int    `json:"status"`
+	Country           string `json:"country"`
+	IsCountryLaunched bool   `json:"is_country_launched"`
+}
+
+// checkSpotify 检测Spotify注册地区
+// 通过注册接口的校验结果判断，不会真正创建账号
+func checkSpotify(ctx *CheckContext) CheckResult {
+	form := url.Values{}
+	form.Set("birth_day", "11")
+	form.Set("birth_month", "11")
+	form.Set("birth_year", "2000")
+	form.Set("collect_personal_info", "undefined")
+	form.Set("creation_flow", "")
+	form.Set("creation_point", "https://www.spotify.com/hk-en/")
+	form.Set("displayname", "ShareSubWeb")
+	form.Set("gender", "male")
+	form.Set("iagree", "1")
+	form.Set("key", "a1e486e2729f46d6bb368d6b2bcda326")
+	form.Set("platform", "www")
+	form.Set("referrer", "")
+	form.Set("send-email", "0")
+	form.Set("thirdpartyemail", "0")
+
+	resp, body, err := fetchPage(ctx.Client, "POST", "https://spclient.wg.spotify.com/signup/public/v1/account",
+		strings.NewReader(form.Encode()), map[string]string{
+			"Content-Type": "application/x-www-form-urlencoded",
+		})
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return CheckResult{Status: model.UnlockBlocked}
+	}
+
+	var result spotifySignupResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return CheckResult{Status: model.UnlockBlocked}
+	}
+
+	// 311表示可以注册，320/120表示地区不支持
+	if result.Status == 311 && result.IsCountryLaunched {
+		return CheckResult{Status: model.UnlockUnlocked, Region: strings.ToUpper(result.Country)}
+	}
+
+	return CheckResult{Status: model.UnlockBlocked, Region: strings.ToUpper(result.Country)}
+}
+
+// checkTikTok 检测TikTok解锁情况
+func checkTikTok(ctx *CheckContext) CheckResult {
+	resp, body, err := fetchPage(ctx.Client, "GET", "https://www.tiktok.com/", nil, nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return CheckResult{Status: model.UnlockBlocked}
+	}
+
+	region := matchRegion(tikTokRegionRe, body)
+	if region == "" {
+		return CheckResult{Status: model.UnlockBlocked}
+	}
+
+	return CheckResult{Status: model.UnlockUnlocked, Region: region}
+}
+
+// checkBBCiPlayer 检测BBC iPlayer解锁情况，仅英国地区可用
+func checkBBCiPlayer(ctx *CheckContext) CheckResult {
+	resp, body, err := fetchPage(ctx.Client, "GET",
+		"https://open.live.bbc.co.uk/mediaselector/6/select/version/2.0/mediaset/pc/vpid/bbc_one_london/format/json/jsfunc/JS_callbacks0",
+		nil, nil)
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		return CheckResult{Status: model.UnlockBlocked}
+	}
+
+	if strings.Contains(string(body), "geolocation") {
+		return CheckResult{Status: model.UnlockBlocked}
+	}
+	if strings.Contains(string(body), "vs-hls-push-uk") {
+		return CheckResult{Status: model.UnlockUnlocked, Region: "GB"}
+	}
+
+	return CheckResult{Status: model.UnlockBlocked}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// rewriteTransport 将所有请求转发到测试服务器，响应中保留原始请求以便读取站点地址
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	forwarded := req.Clone(req.Context())
+	forwarded.URL.Scheme = t.target.Scheme
+	forwarded.URL.Host = t.target.Host
+	forwarded.Host = req.URL.Host
+	resp, err := http.DefaultTransport.RoundTrip(forwarded)
+	if err != nil {
+		return nil, err
+	}
+	resp.Request = req
+	return resp, nil
+}
+
+// mediaCheckContext 启动测试服务器，返回请求均由handler处理的检测上下文
+func mediaCheckContext(t *testing.T, handler http.HandlerFunc) *CheckContext {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	target, _ := url.Parse(server.URL)
+	return &CheckContext{
+		Client: &http.Client{Transport: &rewriteTransport{target: target}},
+		Config: &config.Config{},
+	}
+}
+
+// TestCheckNetflix 非自制剧可打开为完整解锁，仅自制剧可打开为仅自制剧，地区取自页面或地区路径
+func TestCheckNetflix(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    CheckResult
+	}{
+		{
+			name: "完整解锁",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"requestCountry":{"id":"JP","supportedLocales":[]}}`))
+			},
+			want: CheckResult{Status: model.UnlockUnlocked, Region: "JP"},
+		},
+		{
+			name: "完整解锁时地区取自重定向后的地区路径",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/title/"+netflixTitleIDs[0] {
+					http.Redirect(w, r, "/us/title/"+netflixTitleIDs[0], http.StatusFound)
+					return
+				}
+				w.Write([]byte("<html></html>"))
+			},
+			want: CheckResult{Status: model.UnlockUnlocked, Region: "US"},
+		},
+		{
+			name: "仅自制剧",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/title/"+netflixOriginalID {
+					http.NotFound(w, r)
+					return
+				}
+				w.Write([]byte(`"requestCountry":{"id":"SG"}`))
+			},
+			want: CheckResult{Status: model.UnlockOriginalsOnly, Region: "SG"},
+		},
+		{
+			name: "自制剧也无法打开",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.NotFound(w, r)
+			},
+			want: CheckResult{Status: model.UnlockBlocked},
+		},
+		{
+			name: "地区封锁",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+			},
+			want: CheckResult{Status: model.UnlockBlocked},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := checkNetflix(mediaCheckContext(t, test.handler)); got != test.want {
+				t.Errorf("检测结果为 %+v，期望 %+v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestCheckDisneyPlus 地区取自页面数据，跳转到unavailable为不可用，跳转到preview为仅地区
+func TestCheckDisneyPlus(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    CheckResult
+	}{
+		{
+			name: "解锁",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`<script>window.__DATA__={"countryCode" : "jp","lang":"ja"}</script>`))
+			},
+			want: CheckResult{Status: model.UnlockUnlocked, Region: "JP"},
+		},
+		{
+			name: "即将上线的地区",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/" {
+					http.Redirect(w, r, "/preview", http.StatusFound)
+					return
+				}
+				w.Write([]byte(`{"region":"TH"}`))
+			},
+			want: CheckResult{Status: model.UnlockRegionOnly, Region: "TH"},
+		},
+		{
+			name: "不支持的地区",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/" {
+					http.Redirect(w, r, "/unavailable", http.StatusFound)
+					return
+				}
+				w.Write([]byte(`{"region":"CN"}`))
+			},
+			want: CheckResult{Status: model.UnlockBlocked},
+		},
+		{
+			name: "请求被拒绝",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+			},
+			want: CheckResult{Status: model.UnlockBlocked},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := checkDisneyPlus(mediaCheckContext(t, test.handler)); got != test.want {
+				t.Errorf("检测结果为 %+v，期望 %+v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestCheckNetflixNetworkError 请求失败时为不可用
+func TestCheckNetflixNetworkError(t *testing.T) {
+	target, _ := url.Parse("http://127.0.0.1:1")
+	ctx := &CheckContext{Client: &http.Client{Transport: &rewriteTransport{target: target}}, Config: &config.Config{}}
+	if got := checkNetflix(ctx); got.Status != model.UnlockBlocked {
+		t.Errorf("检测结果为 %+v", got)
+	}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// newCheckServer 启动测试服务器，各路径返回不同的状态码、响应头、响应体与重定向
+func newCheckServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Region", "JP")
+		w.Write([]byte(`{"country":"JP","available":true}`))
+	})
+	mux.HandleFunc("/forbidden", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("not available in your country"))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/blocked/region", http.StatusFound)
+	})
+	mux.HandleFunc("/blocked/region", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("blocked"))
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method + " " + r.Header.Get("X-Token")))
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("a", maxCheckBodySize)))
+		w.Write([]byte("MARKER"))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestRuleChecker 按顺序匹配状态码、响应体、响应头与重定向条件，取第一个命中的规则
+func TestRuleChecker(t *testing.T) {
+	server := newCheckServer(t)
+
+	tests := []struct {
+		name string
+		item config.APIItemConfig
+		want model.UnlockStatus
+	}{
+		{
+			name: "未配置规则时2xx为解锁",
+			item: config.APIItemConfig{URL: server.URL + "/ok"},
+			want: model.UnlockUnlocked,
+		},
+		{
+			name: "未配置规则时4xx为不可用",
+			item: config.APIItemConfig{URL: server.URL + "/forbidden"},
+			want: model.UnlockBlocked,
+		},
+		{
+			name: "状态码",
+			item: config.APIItemConfig{URL: server.URL + "/forbidden", Rules: []config.APIMatchRule{
+				{Status: []int{200}},
+				{Status: []int{401, 403}, Result: "region-only"},
+			}},
+			want: model.UnlockRegionOnly,
+		},
+		{
+			name: "响应体正则",
+			item: config.APIItemConfig{URL: server.URL + "/ok", Rules: []config.APIMatchRule{
+				{BodyRegex: `"country":"US"`},
+				{BodyRegex: `"available":true`, Result: "originals-only"},
+			}},
+			want: model.UnlockOriginalsOnly,
+		},
+		{
+			name: "响应头",
+			item: config.APIItemConfig{URL: server.URL + "/ok", Rules: []config.APIMatchRule{
+				{Header: map[string]string{"X-Region": "^US$"}, Result: "blocked"},
+				{Header: map[string]string{"X-Region": "^JP$"}},
+			}},
+			want: model.UnlockUnlocked,
+		},
+		{
+			name: "规则内所有条件均需满足",
+			item: config.APIItemConfig{URL: server.URL + "/ok", Rules: []config.APIMatchRule{
+				{Status: []int{200}, BodyRegex: "JP", Header: map[string]string{"X-Region": "US"}},
+			}},
+			want: model.UnlockBlocked,
+		},
+		{
+			name: "重定向目标不跟随重定向",
+			item: config.APIItemConfig{URL: server.URL + "/redirect", Rules: []config.APIMatchRule{
+				{Status: []int{302}, Redirect: "/blocked/", Result: "blocked"},
+				{Status: []int{200}},
+			}},
+			want: model.UnlockBlocked,
+		},
+		{
+			name: "无重定向条件时跟随重定向",
+			item: config.APIItemConfig{URL: server.URL + "/redirect", Rules: []config.APIMatchRule{
+				{Status: []int{200}, BodyRegex: "^blocked$", Result: "region-only"},
+			}},
+			want: model.UnlockRegionOnly,
+		},
+		{
+			name: "请求方法与请求头",
+			item: config.APIItemConfig{URL: server.URL + "/echo", Method: "POST", Headers: map[string]string{"X-Token": "secret"}, Rules: []config.APIMatchRule{
+				{BodyRegex: "^POST secret$"},
+			}},
+			want: model.UnlockUnlocked,
+		},
+		{
+			name: "均未命中时为不可用",
+			item: config.APIItemConfig{URL: server.URL + "/ok", Rules: []config.APIMatchRule{
+				{Status: []int{500}},
+			}},
+			want: model.UnlockBlocked,
+		},
+		{
+			name: "响应体超过上限的部分不参与匹配",
+			item: config.APIItemConfig{URL: server.URL + "/large", Rules: []config.APIMatchRule{
+				{BodyRegex: "MARKER"},
+			}},
+			want: model.UnlockBlocked,
+		},
+		{
+			name: "请求失败时为不可用",
+			item: config.APIItemConfig{URL: "http://127.0.0.1:1/", Rules: []config.APIMatchRule{
+				{},
+			}},
+			want: model.UnlockBlocked,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checker, err := NewRuleChecker(test.item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := checker.Check(&CheckContext{Client: server.Client(), Config: &config.Config{}, Item: test.item})
+			if result.Status != test.want {
+				t.Errorf("检测结果为 %s，期望 %s", result.Status, test.want)
+			}
+		})
+	}
+}
+
+// TestNewRuleCheckerError 缺少URL、无效的正则与未知的结果类型返回错误
+func TestNewRuleCheckerError(t *testing.T) {
+	for _, item := range []config.APIItemConfig{
+		{Name: "empty"},
+		{URL: "http://example.com", Rules: []config.APIMatchRule{{BodyRegex: "("}}},
+		{URL: "http://example.com", Rules: []config.APIMatchRule{{Header: map[string]string{"X-A": "[a-"}}}},
+		{URL: "http://example.com", Rules: []config.APIMatchRule{{Redirect: "("}}},
+		{URL: "http://example.com", Rules: []config.APIMatchRule{{Result: "maybe"}}},
+	} {
+		if _, err := NewRuleChecker(item); err == nil {
+			t.Errorf("API项 %+v 应返回错误", item)
+		}
+	}
+}
@@ -0,0 +1,689 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/filter"
+	"github.com/nariahlamb/sharesubweb/model"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Clash模板占位符
+//
+// 顶层字段的值为占位符时整体替换，列表中的占位符展开为多个元素：
+//
+//	proxies: "{{proxies}}"
+//	proxy-groups:
+//	  - name: "Proxy"
+//	    type: select
+//	    proxies: [ "{{regions}}", "{{nodes}}" ]
+//	  - "{{region-groups}}"
+//	rules:
+//	  - "{{rules}}"
+const (
+	placeholderProxies        = "{{proxies}}"         // 所有节点
+	placeholderProxyGroups    = "{{proxy-groups}}"    // 所有自动生成的代理组
+	placeholderRegionGroups   = "{{region-groups}}"   // 地区代理组
+	placeholderServiceGroups  = "{{service-groups}}"  // 服务代理组
+	placeholderRuleProviders  = "{{rule-providers}}"  // 规则集
+	placeholderProxyProviders = "{{proxy-providers}}" // 代理集，启用proxy-provider时生成
+	placeholderRules          = "{{rules}}"           // 规则
+	placeholderNodes          = "{{nodes}}"           // 代理组中的所有节点名称
+	placeholderRegions        = "{{regions}}"         // 代理组中的地区代理组名称
+	placeholderServices       = "{{services}}"        // 代理组中的服务代理组名称
+)
+
+// defaultClashTemplate 内置Clash模板
+const defaultClashTemplate = `port: 7890
+socks-port: 7891
+allow-lan: true
+mode: rule
+log-level: info
+external-controller: 127.0.0.1:9090
+proxies: "{{proxies}}"
+proxy-groups:
+  - "{{proxy-groups}}"
+proxy-providers: "{{proxy-providers}}"
+rule-providers: "{{rule-providers}}"
+rules:
+  - "{{rules}}"
+`
+
+// defaultClashRules 未配置规则集与规则时使用的内置规则
+var defaultClashRules = []string{
+	"DOMAIN-SUFFIX,google.com,Proxy",
+	"DOMAIN-SUFFIX,github.com,Proxy",
+	"DOMAIN-SUFFIX,openai.com,Proxy",
+	"DOMAIN-SUFFIX,githubusercontent.com,Proxy",
+	"DOMAIN-KEYWORD,google,Proxy",
+	"DOMAIN-KEYWORD,github,Proxy",
+	"DOMAIN-KEYWORD,openai,Proxy",
+}
+
+// clashTypes Clash.Meta支持的代理类型
+var clashTypes = map[string]bool{
+	"ss": true, "ssr": true, "vmess": true, "vless": true, "trojan": true,
+	"hysteria": true, "hysteria2": true, "tuic": true, "wireguard": true,
+	"socks5": true, "http": true, "snell": true,
+}
+
+// clashProxy 生成节点的Clash.Meta代理配置
+// 先由节点字段生成规范的配置，再合并Clash订阅中的原始字段，其他输出格式也以此为准进行转换
+func clashProxy(node *model.ProxyNode) map[string]interface{} {
+	proxy := map[string]interface{}{
+		"name":   node.Name,
+		"type":   node.Type,
+		"server": node.Server,
+		"port":   node.Port,
+	}
+
+	// 根据节点类型设置特定属性
+	switch node.Type {
+	case "ss":
+		proxy["password"] = node.Password
+		proxy["cipher"] = node.Cipher
+	case "vmess", "vless":
+		proxy["uuid"] = node.UUID
+		if node.TLS {
+			proxy["tls"] = true
+			if node.SNI != "" {
+				proxy["servername"] = node.SNI
+			}
+			if node.ALPN != "" {
+				proxy["alpn"] = strings.Split(node.ALPN, ",")
+			}
+		}
+		setClashTransport(proxy, node)
+	case "trojan":
+		proxy["password"] = node.Password
+		if node.SNI != "" {
+			proxy["sni"] = node.SNI
+		}
+		if node.ALPN != "" {
+			proxy["alpn"] = strings.Split(node.ALPN, ",")
+		}
+	}
+	if node.UDP {
+		proxy["udp"] = true
+	}
+
+	// 合并Clash订阅中的原始字段，保留插件、证书校验、指纹等未解析的配置
+	if node.RawFormat == model.RawFormatClash {
+		mergeRawData(proxy, node.RawData)
+	}
+	if node.Type == "vmess" {
+		if _, ok := proxy["alterId"]; !ok {
+			proxy["alterId"] = 0
+		}
+	}
+	return proxy
+}
+
+// setClashTransport 设置vmess/vless的传输层配置
+func setClashTransport(proxy map[string]interface{}, node *model.ProxyNode) {
+	if node.Network == "" {
+		return
+	}
+
+	proxy["network"] = node.Network
+	switch node.Network {
+	case "ws":
+		if node.Path != "" {
+			wsOpts := map[string]interface{}{
+				"path": node.Path,
+			}
+			if node.Host != "" {
+				wsOpts["headers"] = map[string]interface{}{
+					"Host": node.Host,
+				}
+			}
+			proxy["ws-opts"] = wsOpts
+		}
+	case "grpc":
+		if node.ServiceName != "" {
+			proxy["grpc-opts"] = map[string]interface{}{
+				"grpc-service-name": node.ServiceName,
+			}
+		}
+	}
+}
+
+// clashSupported Clash.Meta能否表示该代理
+func clashSupported(proxy map[string]interface{}) bool {
+	if !clashTypes[stringField(proxy, "type")] {
+		return false
+	}
+	return stringField(proxy, "server") != "" && intField(proxy, "port") > 0
+}
+
+// clashParts 生成Clash配置所需的各部分内容
+type clashParts struct {
+	proxies        []map[string]interface{}
+	nodeNames      []string
+	mainGroups     []ClashProxyGroup // Proxy与Auto
+	regionGroups   []ClashProxyGroup
+	serviceGroups  []ClashProxyGroup
+	providerNames  []string
+	providers      map[string]map[string]interface{}
+	rules          []string
+	proxyProvider  string // 代理集名称，为空时节点内联在配置中
+	proxyProviders map[string]map[string]interface{}
+}
+
+// buildClashParts 根据节点与Clash输出配置生成代理组、规则集与规则
+func buildClashParts(cfg config.ClashOutputConfig, language string, nodes []*model.ProxyNode, proxies []map[string]interface{}) (*clashParts, error) {
+	testURL := cfg.TestURL
+	if testURL == "" {
+		testURL = "http://www.gstatic.com/generate_204"
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = 300
+	}
+
+	parts := &clashParts{proxies: proxies}
+	for _, node := range nodes {
+		parts.nodeNames = append(parts.nodeNames, node.Name)
+	}
+
+	if cfg.RegionGroups.Enable {
+		parts.regionGroups = buildRegionGroups(cfg.RegionGroups, language, nodes, testURL, interval)
+	}
+
+	for _, groupCfg := range cfg.ServiceGroups {
+		group, err := buildServiceGroup(groupCfg, nodes, testURL, interval)
+		if err != nil {
+			return nil, err
+		}
+		parts.serviceGroups = append(parts.serviceGroups, group)
+	}
+
+	// Proxy包含自动测速、地区代理组、直连与所有节点
+	selectProxies := []string{"Auto"}
+	for _, group := range parts.regionGroups {
+		selectProxies = append(selectProxies, group.Name)
+	}
+	selectProxies = append(selectProxies, "DIRECT")
+	selectProxies = append(selectProxies, parts.nodeNames...)
+	parts.mainGroups = []ClashProxyGroup{
+		{Name: "Proxy", Type: "select", Proxies: selectProxies},
+		{Name: "Auto", Type: "url-test", URL: testURL, Interval: interval, Proxies: append([]string{}, parts.nodeNames...)},
+	}
+
+	if err := parts.buildRules(cfg); err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// useProxyProvider 改为通过代理集引用节点：配置中不再内联节点，代理组通过use引用代理集
+// 只包含部分节点的代理组使用filter按名称筛选代理集中的节点
+func (p *clashParts) useProxyProvider(cfg config.ProxyProviderConfig, profile, token string) {
+	name := cfg.Name
+	if name == "" {
+		name = "nodes"
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = 3600
+	}
+	p.proxyProvider = name
+	p.proxyProviders = map[string]map[string]interface{}{
+		name: {
+			"type":     "http",
+			"url":      providerURL(cfg.URL, profile, token),
+			"interval": interval,
+			"path":     fmt.Sprintf("./proxy_providers/%s.yaml", name),
+			"health-check": map[string]interface{}{
+				"enable":   true,
+				"url":      p.mainGroups[1].URL,
+				"interval": p.mainGroups[1].Interval,
+			},
+		},
+	}
+
+	isNode := make(map[string]bool, len(p.nodeNames))
+	for _, nodeName := range p.nodeNames {
+		isNode[nodeName] = true
+	}
+	for _, groups := range [][]ClashProxyGroup{p.mainGroups, p.regionGroups, p.serviceGroups} {
+		for i := range groups {
+			group := &groups[i]
+			var proxies, members []string
+			for _, member := range group.Proxies {
+				if isNode[member] {
+					members = append(members, member)
+				} else {
+					proxies = append(proxies, member)
+				}
+			}
+			if len(members) == 0 {
+				continue
+			}
+
+			group.Proxies = proxies
+			group.Use = []string{name}
+			if len(members) < len(p.nodeNames) {
+				quoted := make([]string, len(members))
+				for j, member := range members {
+					quoted[j] = regexp.QuoteMeta(member)
+				}
+				group.Filter = "^(?:" + strings.Join(quoted, "|") + ")$"
+			}
+		}
+	}
+	p.proxies = nil
+}
+
+// providerURL 替换代理集地址中的{profile}与{token}，令牌作为查询参数进行转义
+func providerURL(template, profile, token string) string {
+	return strings.NewReplacer("{profile}", url.PathEscape(profile), "{token}", url.QueryEscape(token)).Replace(template)
+}
+
+// buildRegionGroups 按节点出口国家生成代理组，顺序与节点首次出现的顺序一致
+func buildRegionGroups(cfg config.RegionGroupConfig, language string, nodes []*model.ProxyNode, testURL string, interval int) []ClashProxyGroup {
+	groupType := cfg.Type
+	if groupType == "" {
+		groupType = "url-test"
+	}
+
+	var codes []string
+	members := make(map[string][]string)
+	for _, node := range nodes {
+		if node.IPInfo == nil || node.IPInfo.CountryCode == "" {
+			continue
+		}
+		code := strings.ToUpper(node.IPInfo.CountryCode)
+		if _, ok := members[code]; !ok {
+			codes = append(codes, code)
+		}
+		members[code] = append(members[code], node.Name)
+	}
+
+	groups := make([]ClashProxyGroup, 0, len(codes))
+	for _, code := range codes {
+		if len(members[code]) < cfg.MinNodes {
+			continue
+		}
+		group := ClashProxyGroup{
+			Name:     strings.TrimSpace(model.CountryFlag(code) + " " + model.CountryName(code, language)),
+			Type:     groupType,
+			URL:      testURL,
+			Interval: interval,
+			Proxies:  members[code],
+		}
+		switch groupType {
+		case "url-test":
+			group.Tolerance = cfg.Tolerance
+		case "load-balance":
+			group.Strategy = cfg.Strategy
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// buildServiceGroup 生成服务代理组，没有满足条件的节点时回落到Proxy
+func buildServiceGroup(cfg config.ServiceGroupConfig, nodes []*model.ProxyNode, testURL string, interval int) (ClashProxyGroup, error) {
+	expression, err := filter.Compile(cfg.Filter)
+	if err != nil {
+		return ClashProxyGroup{}, fmt.Errorf("服务代理组 %s 的过滤表达式无效: %v", cfg.Name, err)
+	}
+
+	var names []string
+	for _, node := range nodes {
+		if expression.Match(node) && serviceAvailable(node, cfg.APIs) {
+			names = append(names, node.Name)
+		}
+	}
+
+	if len(names) == 0 {
+		return ClashProxyGroup{Name: cfg.Name, Type: "select", Proxies: []string{"Proxy"}}, nil
+	}
+
+	group := ClashProxyGroup{Name: cfg.Name, Type: cfg.Type, Proxies: names}
+	switch group.Type {
+	case "":
+		group.Type = "url-test"
+		fallthrough
+	case "url-test", "fallback", "load-balance":
+		group.URL = testURL
+		group.Interval = interval
+	case "select":
+		group.Proxies = append([]string{"Proxy"}, names...)
+	}
+	return group, nil
+}
+
+// serviceAvailable 节点是否可用于任一检测项，未指定检测项时视为可用
+func serviceAvailable(node *model.ProxyNode, apis []string) bool {
+	if len(apis) == 0 {
+		return true
+	}
+	for _, name := range apis {
+		if result := node.APIResults[name]; result != nil && result.Status.Available() {
+			return true
+		}
+	}
+	return false
+}
+
+// buildRules 生成规则集与规则，未配置时使用内置规则
+func (p *clashParts) buildRules(cfg config.ClashOutputConfig) error {
+	p.providers = make(map[string]map[string]interface{}, len(cfg.RuleProviders))
+	for _, providerCfg := range cfg.RuleProviders {
+		provider, err := buildRuleProvider(providerCfg)
+		if err != nil {
+			return err
+		}
+		p.providerNames = append(p.providerNames, providerCfg.Name)
+		p.providers[providerCfg.Name] = provider
+
+		policy := providerCfg.Policy
+		if policy == "" {
+			policy = "Proxy"
+		}
+		rule := fmt.Sprintf("RULE-SET,%s,%s", providerCfg.Name, policy)
+		if providerCfg.NoResolve {
+			rule += ",no-resolve"
+		}
+		p.rules = append(p.rules, rule)
+	}
+
+	p.rules = append(p.rules, cfg.Rules...)
+	if len(cfg.RuleProviders) == 0 && len(cfg.Rules) == 0 {
+		p.rules = append(p.rules, defaultClashRules...)
+	}
+
+	for _, rule := range p.rules {
+		if strings.HasPrefix(strings.ToUpper(rule), "MATCH,") {
+			return nil
+		}
+	}
+	final := cfg.Final
+	if final == "" {
+		final = "DIRECT"
+	}
+	p.rules = append(p.rules, "MATCH,"+final)
+	return nil
+}
+
+// buildRuleProvider 生成规则集，local类型读取本机文件并以inline规则集输出
+func buildRuleProvider(cfg config.RuleProviderConfig) (map[string]interface{}, error) {
+	provider := map[string]interface{}{
+		"behavior": cfg.Behavior,
+	}
+
+	switch cfg.Type {
+	case "local":
+		payload, err := readRulePayload(cfg.Path)
+		if err != nil {
+			return nil, fmt.Errorf("读取规则集 %s 失败: %v", cfg.Name, err)
+		}
+		provider["type"] = "inline"
+		provider["payload"] = payload
+		return provider, nil
+	case "http":
+		provider["url"] = cfg.URL
+		interval := cfg.Interval
+		if interval <= 0 {
+			interval = 86400
+		}
+		provider["interval"] = interval
+	}
+
+	provider["type"] = cfg.Type
+	if cfg.Path != "" {
+		provider["path"] = cfg.Path
+	} else {
+		provider["path"] = fmt.Sprintf("./ruleset/%s.%s", cfg.Name, ruleFileExtension(cfg.Format))
+	}
+	if cfg.Format != "" {
+		provider["format"] = cfg.Format
+	}
+	return provider, nil
+}
+
+// ruleFileExtension 规则集文件扩展名
+func ruleFileExtension(format string) string {
+	if format == "text" {
+		return "txt"
+	}
+	return "yaml"
+}
+
+// readRulePayload 读取规则集文件，支持带payload字段的YAML与每行一条规则的文本
+func readRulePayload(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ruleSet struct {
+		Payload []string `yaml:"payload"`
+	}
+	if err := yaml.Unmarshal(data, &ruleSet); err == nil && len(ruleSet.Payload) > 0 {
+		return ruleSet.Payload, nil
+	}
+
+	var payload []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || line == "payload:" {
+			continue
+		}
+		payload = append(payload, strings.Trim(strings.TrimPrefix(line, "- "), `'"`))
+	}
+	return payload, nil
+}
+
+// renderClashTemplate 将生成的内容填入Clash模板
+func renderClashTemplate(template []byte, parts *clashParts) (string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(template, &doc); err != nil {
+		return "", fmt.Errorf("解析Clash模板失败: %v", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return "", fmt.Errorf("Clash模板必须是YAML映射")
+	}
+	root := doc.Content[0]
+
+	proxies, err := encodeNodes(parts.proxies)
+	if err != nil {
+		return "", err
+	}
+	allGroups := append(append(append([]ClashProxyGroup{}, parts.mainGroups...), parts.regionGroups...), parts.serviceGroups...)
+	groups, err := encodeNodes(allGroups)
+	if err != nil {
+		return "", err
+	}
+	regionGroups, err := encodeNodes(parts.regionGroups)
+	if err != nil {
+		return "", err
+	}
+	serviceGroups, err := encodeNodes(parts.serviceGroups)
+	if err != nil {
+		return "", err
+	}
+	rules, err := encodeNodes(parts.rules)
+	if err != nil {
+		return "", err
+	}
+
+	fillSequence(root, "proxies", placeholderProxies, map[string][]*yaml.Node{placeholderProxies: proxies})
+	fillSequence(root, "proxy-groups", placeholderProxyGroups, map[string][]*yaml.Node{
+		placeholderProxyGroups:   groups,
+		placeholderRegionGroups:  regionGroups,
+		placeholderServiceGroups: serviceGroups,
+	})
+	fillSequence(root, "rules", placeholderRules, map[string][]*yaml.Node{placeholderRules: rules})
+
+	// 代理组中的节点名称占位符
+	if groupList := mappingValue(root, "proxy-groups"); groupList != nil && groupList.Kind == yaml.SequenceNode {
+		members := map[string][]*yaml.Node{
+			placeholderNodes:    scalarNodes(parts.nodeNames),
+			placeholderRegions:  scalarNodes(groupNames(parts.regionGroups)),
+			placeholderServices: scalarNodes(groupNames(parts.serviceGroups)),
+		}
+		// 使用代理集时节点不在配置中，包含节点占位符的代理组改为引用代理集
+		if parts.proxyProvider != "" {
+			members[placeholderNodes] = nil
+		}
+		for _, group := range groupList.Content {
+			if group.Kind != yaml.MappingNode {
+				continue
+			}
+			list := mappingValue(group, "proxies")
+			if list == nil || list.Kind != yaml.SequenceNode {
+				continue
+			}
+			if parts.proxyProvider != "" && containsPlaceholder(list.Content, placeholderNodes) && mappingValue(group, "use") == nil {
+				group.Content = append(group.Content, scalarNode("use"), sequenceNode(scalarNodes([]string{parts.proxyProvider})))
+			}
+			list.Content = expandPlaceholders(list.Content, members)
+		}
+	}
+
+	if err := fillProviders(root, "rule-providers", placeholderRuleProviders, parts.providerNames, parts.providers); err != nil {
+		return "", err
+	}
+	var proxyProviderNames []string
+	if parts.proxyProvider != "" {
+		proxyProviderNames = []string{parts.proxyProvider}
+	}
+	if err := fillProviders(root, "proxy-providers", placeholderProxyProviders, proxyProviderNames, parts.proxyProviders); err != nil {
+		return "", err
+	}
+
+	buf := new(bytes.Buffer)
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// fillSequence 填充模板中的列表字段：字段不存在或为占位符时整体替换，为列表时展开其中的占位符
+func fillSequence(root *yaml.Node, key, placeholder string, values map[string][]*yaml.Node) {
+	value := mappingValue(root, key)
+	switch {
+	case value == nil:
+		root.Content = append(root.Content, scalarNode(key), sequenceNode(values[placeholder]))
+	case value.Kind == yaml.ScalarNode && value.Value == placeholder:
+		*value = *sequenceNode(values[placeholder])
+	case value.Kind == yaml.SequenceNode:
+		value.Content = expandPlaceholders(value.Content, values)
+	}
+}
+
+// fillProviders 填充规则集或代理集，模板中已有的条目保留在前
+func fillProviders(root *yaml.Node, key, placeholder string, names []string, providers map[string]map[string]interface{}) error {
+	generated := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, name := range names {
+		var value yaml.Node
+		if err := value.Encode(providers[name]); err != nil {
+			return err
+		}
+		generated.Content = append(generated.Content, scalarNode(name), &value)
+	}
+
+	for i := 0; i < len(root.Content); i += 2 {
+		if root.Content[i].Value != key {
+			continue
+		}
+		value := root.Content[i+1]
+		switch {
+		case value.Kind == yaml.MappingNode:
+			value.Content = append(value.Content, generated.Content...)
+		case value.Kind != yaml.ScalarNode || value.Value != placeholder:
+		case len(generated.Content) == 0:
+			// 没有条目时移除占位符
+			root.Content = append(root.Content[:i], root.Content[i+2:]...)
+		default:
+			root.Content[i+1] = generated
+		}
+		return nil
+	}
+
+	if len(generated.Content) > 0 {
+		root.Content = append(root.Content, scalarNode(key), generated)
+	}
+	return nil
+}
+
+// expandPlaceholders 将列表中的占位符展开为对应的元素
+func expandPlaceholders(items []*yaml.Node, values map[string][]*yaml.Node) []*yaml.Node {
+	result := make([]*yaml.Node, 0, len(items))
+	for _, item := range items {
+		if item.Kind == yaml.ScalarNode {
+			if expanded, ok := values[item.Value]; ok {
+				result = append(result, expanded...)
+				continue
+			}
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// containsPlaceholder 列表中是否包含指定占位符
+func containsPlaceholder(items []*yaml.Node, placeholder string) bool {
+	for _, item := range items {
+		if item.Kind == yaml.ScalarNode && item.Value == placeholder {
+			return true
+		}
+	}
+	return false
+}
+
+// mappingValue 获取映射中指定字段的值
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// encodeNodes 将切片编码为YAML节点列表
+func encodeNodes(items interface{}) ([]*yaml.Node, error) {
+	var node yaml.Node
+	if err := node.Encode(items); err != nil {
+		return nil, err
+	}
+	return node.Content, nil
+}
+
+// scalarNodes 将字符串列表转换为YAML节点
+func scalarNodes(values []string) []*yaml.Node {
+	nodes := make([]*yaml.Node, 0, len(values))
+	for _, value := range values {
+		nodes = append(nodes, scalarNode(value))
+	}
+	return nodes
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func sequenceNode(items []*yaml.Node) *yaml.Node {
+	return &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: items}
+}
+
+// groupNames 代理组名称列表
+func groupNames(groups []ClashProxyGroup) []string {
+	names := make([]string, 0, len(groups))
+	for _, group := range groups {
+		names = append(names, group.Name)
+	}
+	return names
+}
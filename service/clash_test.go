@@ -0,0 +1,193 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var update = flag.Bool("update", false, "更新golden文件")
+
+// clashProxies 读取Clash配置中的代理列表
+func clashProxies(t *testing.T, data []byte) []map[string]interface{} {
+	t.Helper()
+	var clashConfig struct {
+		Proxies []map[string]interface{} `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal(data, &clashConfig); err != nil {
+		t.Fatalf("解析Clash配置失败: %v", err)
+	}
+	return clashConfig.Proxies
+}
+
+// parseClashTestdata 解析测试数据中的Clash订阅，节点均标记为可用
+func parseClashTestdata(t *testing.T, input string) ([]byte, []*model.ProxyNode) {
+	t.Helper()
+	data, err := ioutil.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub := &model.Subscription{ID: input, Name: input, Type: "clash"}
+	if err := NewSubscriptionService(&config.Config{}).parseClashSubscription(sub, data); err != nil {
+		t.Fatalf("解析订阅失败: %v", err)
+	}
+	for _, node := range sub.Nodes {
+		node.Active = true
+	}
+	return data, sub.Nodes
+}
+
+// checkGolden 与golden文件比较，使用 -update 时更新golden文件
+func checkGolden(t *testing.T, golden string, actual []byte) {
+	t.Helper()
+	if *update {
+		if err := ioutil.WriteFile(golden, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("读取golden文件失败: %v，可使用 -update 生成", err)
+	}
+	if string(actual) != string(expected) {
+		t.Errorf("输出与 %s 不一致\n输出:\n%s\n期望:\n%s", golden, actual, expected)
+	}
+}
+
+// clashTestdata 每种协议一个Clash订阅文件
+func clashTestdata(t *testing.T) []string {
+	t.Helper()
+	inputs, err := filepath.Glob(filepath.Join("testdata", "clash", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("没有找到测试数据")
+	}
+	return inputs
+}
+
+// TestClashGolden 每种协议的Clash订阅经过解析与输出后与golden文件一致，且原始字段无丢失
+func TestClashGolden(t *testing.T) {
+	for _, input := range clashTestdata(t) {
+		name := strings.TrimSuffix(filepath.Base(input), ".yaml")
+		t.Run(name, func(t *testing.T) {
+			data, nodes := parseClashTestdata(t, input)
+
+			output, err := NewOutputGenerator(&config.Config{}).GenerateClashConfig(nodes)
+			if err != nil {
+				t.Fatalf("生成Clash配置失败: %v", err)
+			}
+			generated := clashProxies(t, []byte(output))
+
+			// 输出的代理与输入逐字段一致
+			source := clashProxies(t, data)
+			if len(generated) != len(source) {
+				t.Fatalf("代理数量为 %d，期望 %d", len(generated), len(source))
+			}
+			byName := make(map[string]map[string]interface{}, len(generated))
+			for _, proxy := range generated {
+				byName[proxy["name"].(string)] = proxy
+			}
+			for _, want := range source {
+				got := byName[want["name"].(string)]
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("代理 %v 不一致\n输出: %#v\n输入: %#v", want["name"], got, want)
+				}
+			}
+
+			actual, err := yaml.Marshal(map[string]interface{}{"proxies": generated})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, filepath.Join("testdata", "clash", name+".golden"), actual)
+		})
+	}
+}
+
+// TestProtocolGolden 每种协议转换为sing-box出站与分享链接的结果与golden文件一致，无法表示的节点被跳过
+func TestProtocolGolden(t *testing.T) {
+	for _, input := range clashTestdata(t) {
+		name := strings.TrimSuffix(filepath.Base(input), ".yaml")
+		t.Run(name, func(t *testing.T) {
+			_, nodes := parseClashTestdata(t, input)
+			sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+			var outbounds bytes.Buffer
+			for _, node := range nodes {
+				outbound, ok := singBoxOutbound(clashProxy(node), node.Name)
+				if !ok {
+					fmt.Fprintf(&outbounds, "# %s: 不支持\n", node.Name)
+					continue
+				}
+				data, err := json.Marshal(outbound)
+				if err != nil {
+					t.Fatal(err)
+				}
+				fmt.Fprintf(&outbounds, "%s\n", data)
+			}
+			checkGolden(t, filepath.Join("testdata", "clash", name+".singbox.golden"), outbounds.Bytes())
+
+			content, err := NewOutputGenerator(&config.Config{}).GenerateBase64Config(nodes)
+			if err != nil {
+				t.Fatalf("生成分享链接失败: %v", err)
+			}
+			links, err := base64.StdEncoding.DecodeString(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(links) > 0 {
+				links = append(links, '\n')
+			}
+			checkGolden(t, filepath.Join("testdata", "clash", name+".uri.golden"), links)
+		})
+	}
+}
+
+// TestMergeRawData 生成的字段优先，嵌套映射逐层合并且不修改原始数据
+func TestMergeRawData(t *testing.T) {
+	raw := map[string]interface{}{
+		"name":             "原始名称",
+		"skip-cert-verify": true,
+		"ws-opts": map[string]interface{}{
+			"path":           "/old",
+			"max-early-data": 2048,
+		},
+	}
+	proxy := map[string]interface{}{
+		"name": "新名称",
+		"ws-opts": map[string]interface{}{
+			"path": "/new",
+		},
+	}
+
+	mergeRawData(proxy, raw)
+
+	want := map[string]interface{}{
+		"name":             "新名称",
+		"skip-cert-verify": true,
+		"ws-opts": map[string]interface{}{
+			"path":           "/new",
+			"max-early-data": 2048,
+		},
+	}
+	if !reflect.DeepEqual(proxy, want) {
+		t.Errorf("合并结果为 %#v，期望 %#v", proxy, want)
+	}
+	if raw["ws-opts"].(map[string]interface{})["path"] != "/old" {
+		t.Error("原始数据被修改")
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// clientConfig Surge、Quantumult X、Loon等客户端配置的组成部分，代理组与规则由Clash输出配置生成
+type clientConfig struct {
+	lines   []string                 // 各客户端语法的节点
+	proxies []map[string]interface{} // 与lines一一对应的Clash.Meta代理配置
+	groups  []ClashProxyGroup
+	rules   []clientRule
+	testURL string
+}
+
+// clientRule 可在各客户端间转换的规则
+type clientRule struct {
+	Type    string   // Clash规则类型，如DOMAIN-SUFFIX，MATCH表示兜底规则
+	Value   string   // MATCH规则为空
+	Policy  string   // 代理组名称或DIRECT、REJECT
+	Options []string // 附加参数，如no-resolve
+}
+
+// clientRuleTypes 各客户端均支持的规则类型，其余Clash规则与RULE-SET被跳过
+var clientRuleTypes = map[string]bool{
+	"DOMAIN":         true,
+	"DOMAIN-SUFFIX":  true,
+	"DOMAIN-KEYWORD": true,
+	"IP-CIDR":        true,
+	"IP-CIDR6":       true,
+	"GEOIP":          true,
+	"MATCH":          true,
+}
+
+// clientNameReplacer 客户端配置中逗号与等号是分隔符，不能出现在节点名称中
+var clientNameReplacer = strings.NewReplacer(",", " ", "=", " ")
+
+// buildClientConfig 处理节点并生成代理组与规则，convert将Clash.Meta代理转换为客户端语法，无法表示时返回false
+func (g *OutputGenerator) buildClientConfig(nodes []*model.ProxyNode, convert func(proxy map[string]interface{}) (string, bool)) (*clientConfig, error) {
+	client := &clientConfig{}
+	used := map[string]bool{"Proxy": true, "Auto": true, "DIRECT": true, "REJECT": true}
+	supported := make([]*model.ProxyNode, 0, len(nodes))
+	for _, node := range g.processor.Process(activeNodes(nodes)) {
+		name := strings.TrimSpace(clientNameReplacer.Replace(node.Name))
+		if name == "" {
+			name = node.Server
+		}
+
+		proxy := clashProxy(node)
+		proxy["name"] = name
+		line, ok := convert(proxy)
+		if !ok {
+			continue
+		}
+
+		// 名称替换后可能重复，重新生成节点行
+		if unique := uniqueTag(used, name); unique != name {
+			proxy["name"] = unique
+			line, _ = convert(proxy)
+		}
+		node.Name = stringField(proxy, "name")
+		client.lines = append(client.lines, line)
+		client.proxies = append(client.proxies, proxy)
+		supported = append(supported, node)
+	}
+
+	parts, err := buildClashParts(g.cfg.Output.Clash, g.processor.rename.Language, supported, client.proxies)
+	if err != nil {
+		return nil, err
+	}
+
+	// 没有成员的代理组使用直连
+	for _, group := range append(append(append([]ClashProxyGroup{}, parts.mainGroups...), parts.regionGroups...), parts.serviceGroups...) {
+		if len(group.Proxies) == 0 {
+			group.Proxies = []string{"DIRECT"}
+		}
+		client.groups = append(client.groups, group)
+	}
+	client.testURL = parts.mainGroups[1].URL
+
+	for _, rule := range parts.rules {
+		fields := strings.Split(rule, ",")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if !clientRuleTypes[fields[0]] {
+			continue
+		}
+		if fields[0] == "MATCH" {
+			if len(fields) >= 2 {
+				client.rules = append(client.rules, clientRule{Type: "MATCH", Policy: fields[1]})
+			}
+			continue
+		}
+		if len(fields) < 3 {
+			continue
+		}
+		client.rules = append(client.rules, clientRule{Type: fields[0], Value: fields[1], Policy: fields[2], Options: fields[3:]})
+	}
+	return client, nil
+}
+
+// joinOptions 拼接参数，忽略空参数
+func joinOptions(sep string, options ...string) string {
+	values := make([]string, 0, len(options))
+	for _, option := range options {
+		if option != "" {
+			values = append(values, option)
+		}
+	}
+	return strings.Join(values, sep)
+}
+
+// keyValue 生成 key=value 形式的参数，值为空时返回空字符串
+func keyValue(key, value string) string {
+	if value == "" {
+		return ""
+	}
+	return key + "=" + value
+}
+
+// keyTrue 条件成立时生成 key=true 形式的参数
+func keyTrue(key string, value bool) string {
+	if !value {
+		return ""
+	}
+	return key + "=true"
+}
+
+// clientTLS 生成TLS参数，sniKey为Clash中服务器名称的字段名
+func clientTLS(proxy map[string]interface{}, sniKey string) []string {
+	return []string{
+		keyValue("sni", stringField(proxy, sniKey)),
+		keyTrue("skip-cert-verify", boolField(proxy, "skip-cert-verify")),
+	}
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// clientFormats User-Agent关键字对应的输出格式，按顺序匹配，格式按优先级排列
+// Stash的User-Agent同时包含Clash，需要先于Clash匹配
+var clientFormats = []struct {
+	keyword string
+	formats []string
+}{
+	{"stash", []string{"stash", "clash"}},
+	{"shadowrocket", []string{"shadowrocket", "v2ray"}},
+	{"quantumult", []string{"quanx"}},
+	{"loon", []string{"loon"}},
+	{"surge", []string{"surge"}},
+	{"sing-box", []string{"singbox"}},
+	{"sfi/", []string{"singbox"}},
+	{"sfa/", []string{"singbox"}},
+	{"sfm/", []string{"singbox"}},
+	{"sft/", []string{"singbox"}},
+	{"clash", []string{"clash"}},
+	{"mihomo", []string{"clash"}},
+	{"v2ray", []string{"v2ray"}},
+}
+
+// DetectClientFormats 根据User-Agent识别客户端，返回其支持的输出格式，按优先级排列，无法识别时返回nil
+func DetectClientFormats(userAgent string) []string {
+	userAgent = strings.ToLower(userAgent)
+	for _, client := range clientFormats {
+		if strings.Contains(userAgent, client.keyword) {
+			return client.formats
+		}
+	}
+	return nil
+}
+
+// subscriptionUserinfo subscription-userinfo响应头中的流量与到期时间，缺少的字段为零值
+type subscriptionUserinfo struct {
+	upload, download, total int64
+	expire                  time.Time
+}
+
+// parseSubscriptionUserinfo 解析subscription-userinfo响应头
+// 格式为 upload=字节数; download=字节数; total=字节数; expire=Unix时间戳
+func parseSubscriptionUserinfo(header string) subscriptionUserinfo {
+	var info subscriptionUserinfo
+	for _, field := range strings.Split(header, ";") {
+		pair := strings.SplitN(field, "=", 2)
+		if len(pair) != 2 {
+			continue
+		}
+		number, err := strconv.ParseFloat(strings.TrimSpace(pair[1]), 64)
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(pair[0])) {
+		case "upload":
+			info.upload = int64(number)
+		case "download":
+			info.download = int64(number)
+		case "total":
+			info.total = int64(number)
+		case "expire":
+			if number > 0 {
+				info.expire = time.Unix(int64(number), 0)
+			}
+		}
+	}
+	return info
+}
+
+// apply 记录订阅的流量与到期时间，响应头中缺少的字段会被清空
+func (info subscriptionUserinfo) apply(sub *model.Subscription) {
+	sub.UploadBytes = info.upload
+	sub.DownloadBytes = info.download
+	sub.TotalBytes = info.total
+	sub.ExpiryTime = info.expire
+}
+
+// setSubscriptionUserinfo 在写锁内更新已保存订阅的流量信息，避免与读取流量信息的请求竞争
+func (s *SubscriptionService) setSubscriptionUserinfo(sub *model.Subscription, header string) {
+	info := parseSubscriptionUserinfo(header)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	info.apply(sub)
+}
+
+// SubscriptionUserinfo 汇总节点所属订阅的流量信息，生成subscription-userinfo响应头
+func (s *SubscriptionService) SubscriptionUserinfo(nodes []*model.ProxyNode) string {
+	ids := make(map[string]bool)
+	for _, node := range nodes {
+		ids[node.SubscriptionID] = true
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var subs []*model.Subscription
+	for _, sub := range s.sortedSubscriptions() {
+		if ids[sub.ID] {
+			subs = append(subs, sub)
+		}
+	}
+	return FormatSubscriptionUserinfo(subs)
+}
+
+// FormatSubscriptionUserinfo 生成subscription-userinfo响应头
+// 流量取各订阅之和，到期时间取最早的到期时间，所有订阅均无流量信息时返回空字符串
+func FormatSubscriptionUserinfo(subs []*model.Subscription) string {
+	var upload, download, total int64
+	var expire time.Time
+	found := false
+	for _, sub := range subs {
+		if sub.TotalBytes == 0 && sub.UploadBytes == 0 && sub.DownloadBytes == 0 && sub.ExpiryTime.IsZero() {
+			continue
+		}
+		found = true
+		upload += sub.UploadBytes
+		download += sub.DownloadBytes
+		total += sub.TotalBytes
+		if !sub.ExpiryTime.IsZero() && (expire.IsZero() || sub.ExpiryTime.Before(expire)) {
+			expire = sub.ExpiryTime
+		}
+	}
+	if !found {
+		return ""
+	}
+
+	userinfo := fmt.Sprintf("upload=%d; download=%d; total=%d", upload, download, total)
+	if !expire.IsZero() {
+		userinfo += fmt.Sprintf("; expire=%d", expire.Unix())
+	}
+	return userinfo
+}
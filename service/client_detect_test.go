@@ -0,0 +1,81 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// TestDetectClientFormats 常见客户端的User-Agent识别为对应格式
+func TestDetectClientFormats(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      []string
+	}{
+		{"clash-verge/v1.7.7", []string{"clash"}},
+		{"ClashforWindows/0.20.39", []string{"clash"}},
+		{"mihomo/1.18.5", []string{"clash"}},
+		{"Stash/2.4.5 Clash/1.9.0", []string{"stash", "clash"}},
+		{"Surge iOS/2920", []string{"surge"}},
+		{"Surge Mac/2590", []string{"surge"}},
+		{"Shadowrocket/2070 CFNetwork/1490.0.4 Darwin/23.2.0", []string{"shadowrocket", "v2ray"}},
+		{"sing-box 1.10.1", []string{"singbox"}},
+		{"SFI/1.10.1 (Build 1; sing-box 1.10.1)", []string{"singbox"}},
+		{"SFA/1.9.3", []string{"singbox"}},
+		{"v2rayN/6.45", []string{"v2ray"}},
+		{"v2rayNG/1.8.19", []string{"v2ray"}},
+		{"Quantumult%20X/1.4.1 (iPhone14,2; iOS 17.2)", []string{"quanx"}},
+		{"Loon/3.1.8 (iPhone; iOS 17.2)", []string{"loon"}},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64)", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := DetectClientFormats(tt.userAgent); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DetectClientFormats(%q) = %v，期望 %v", tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+// TestSubscriptionUserinfo 流量取各订阅之和，到期时间取最早值，只统计节点所属的订阅
+func TestSubscriptionUserinfo(t *testing.T) {
+	s := NewSubscriptionService(&config.Config{})
+	subs := []struct {
+		id, header string
+	}{
+		{"a", "upload=100; download=200; total=1000; expire=1900000000"},
+		{"b", "upload=10;download=20;total=500;expire=1800000000"},
+		{"c", ""},
+		{"d", "upload=1; download=1; total=1"},
+	}
+	for _, item := range subs {
+		sub := &model.Subscription{ID: item.id, Name: item.id}
+		parseSubscriptionUserinfo(item.header).apply(sub)
+		if err := s.AddSubscription(sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a := s.subscriptions["a"]
+	if a.UploadBytes != 100 || a.DownloadBytes != 200 || a.TotalBytes != 1000 || !a.ExpiryTime.Equal(time.Unix(1900000000, 0)) {
+		t.Fatalf("解析结果错误: %+v", a)
+	}
+
+	nodes := []*model.ProxyNode{{SubscriptionID: "a"}, {SubscriptionID: "a"}, {SubscriptionID: "b"}, {SubscriptionID: "c"}}
+	want := "upload=110; download=220; total=1500; expire=1800000000"
+	if got := s.SubscriptionUserinfo(nodes); got != want {
+		t.Errorf("SubscriptionUserinfo() = %q，期望 %q", got, want)
+	}
+
+	if got := s.SubscriptionUserinfo([]*model.ProxyNode{{SubscriptionID: "c"}}); got != "" {
+		t.Errorf("没有流量信息时应返回空字符串，得到 %q", got)
+	}
+
+	// 再次获取订阅时，响应头中缺少的字段被清空
+	s.setSubscriptionUserinfo(a, "upload=5; download=6")
+	if a.UploadBytes != 5 || a.DownloadBytes != 6 || a.TotalBytes != 0 || !a.ExpiryTime.IsZero() {
+		t.Errorf("更新结果错误: %+v", a)
+	}
+}
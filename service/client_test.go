@@ -0,0 +1,97 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// clientProxyFormats 各客户端的节点转换，输出一行节点
+var clientProxyFormats = []struct {
+	name    string
+	convert func(proxy map[string]interface{}) (string, bool)
+}{
+	{"surge", surgeProxy},
+	{"quanx", quanxProxy},
+	{"loon", loonProxy},
+	{"shadowrocket", func(proxy map[string]interface{}) (string, bool) {
+		return shadowrocketURI(proxy, stringField(proxy, "name"))
+	}},
+	{"stash", func(proxy map[string]interface{}) (string, bool) {
+		stash, ok := stashProxy(proxy)
+		if !ok {
+			return "", false
+		}
+		data, err := json.Marshal(stash)
+		return string(data), err == nil
+	}},
+}
+
+// TestClientProxyGolden 每种协议转换为各客户端节点的结果与golden文件一致，无法表示的节点被跳过
+func TestClientProxyGolden(t *testing.T) {
+	for _, input := range clashTestdata(t) {
+		name := strings.TrimSuffix(filepath.Base(input), ".yaml")
+		t.Run(name, func(t *testing.T) {
+			_, nodes := parseClashTestdata(t, input)
+			sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+			var output bytes.Buffer
+			for _, format := range clientProxyFormats {
+				fmt.Fprintf(&output, "[%s]\n", format.name)
+				for _, node := range nodes {
+					line, ok := format.convert(clashProxy(node))
+					if !ok {
+						fmt.Fprintf(&output, "# %s: 不支持\n", node.Name)
+						continue
+					}
+					fmt.Fprintf(&output, "%s\n", line)
+				}
+			}
+			checkGolden(t, filepath.Join("testdata", "clash", name+".clients.golden"), output.Bytes())
+		})
+	}
+}
+
+// TestClientConfigGolden 使用全部测试节点生成的各客户端完整配置与golden文件一致
+func TestClientConfigGolden(t *testing.T) {
+	var nodes []*model.ProxyNode
+	for _, input := range clashTestdata(t) {
+		_, parsed := parseClashTestdata(t, input)
+		nodes = append(nodes, parsed...)
+	}
+
+	cfg := &config.Config{}
+	cfg.Output.Clash.RegionGroups.Enable = true
+	cfg.Output.Clash.Rules = []string{
+		"DOMAIN-SUFFIX,google.com,Proxy",
+		"DOMAIN-KEYWORD,ads,REJECT",
+		"IP-CIDR,192.168.0.0/16,DIRECT,no-resolve",
+		"GEOIP,CN,DIRECT",
+	}
+	cfg.Output.Clash.Final = "Proxy"
+
+	for _, format := range []string{"surge", "quanx", "loon", "shadowrocket", "stash"} {
+		t.Run(format, func(t *testing.T) {
+			content, err := NewOutputGenerator(cfg).Generate(format, nodes)
+			if err != nil {
+				t.Fatalf("生成%s配置失败: %v", format, err)
+			}
+			if format == "shadowrocket" {
+				links, err := base64.StdEncoding.DecodeString(content)
+				if err != nil {
+					t.Fatal(err)
+				}
+				content = string(links) + "\n"
+			}
+			checkGolden(t, filepath.Join("testdata", "clients", format+".golden"), []byte(content))
+		})
+	}
+}
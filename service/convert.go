@@ -0,0 +1,98 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/filter"
+	"github.com/nariahlamb/sharesubweb/model"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ConvertRequest 订阅转换参数
+type ConvertRequest struct {
+	Content  string // 订阅内容，与URL二选一
+	URL      string // 订阅地址
+	Type     string // 订阅类型：clash, v2ray，为空时自动识别
+	Format   string // 目标格式
+	Filter   string // 过滤表达式
+	Rename   string // 重命名模板，为空时保留原名称
+	Template string // Clash模板地址，为空时使用output.clash.template
+}
+
+// Convert 将订阅转换为目标格式，只在内存中解析与生成，不保存订阅与节点
+// 返回转换结果与解析的订阅，订阅包含来源的流量信息
+func Convert(cfg *config.Config, req ConvertRequest) (string, *model.Subscription, error) {
+	if !config.IsOutputFormat(req.Format) {
+		return "", nil, fmt.Errorf("不支持的格式: %s", req.Format)
+	}
+	expression, err := filter.Compile(req.Filter)
+	if err != nil {
+		return "", nil, fmt.Errorf("过滤表达式无效: %v", err)
+	}
+
+	// 获取订阅内容
+	sub := &model.Subscription{ID: "convert", Name: "convert", URL: req.URL, Type: req.Type}
+	data := []byte(req.Content)
+	switch {
+	case req.URL != "":
+		body, header, err := fetchURL(req.URL)
+		if err != nil {
+			return "", nil, fmt.Errorf("获取订阅失败: %v", err)
+		}
+		parseSubscriptionUserinfo(header.Get("subscription-userinfo")).apply(sub)
+		data = body
+	case strings.TrimSpace(req.Content) == "":
+		return "", nil, errors.New("订阅内容与订阅地址不能同时为空")
+	}
+	if sub.Type == "" {
+		sub.Type = detectSubscriptionType(data)
+	}
+
+	// 解析订阅，未检测的节点均视为可用
+	if err := NewSubscriptionService(&config.Config{}).parseSubscription(sub, data); err != nil {
+		return "", nil, err
+	}
+	for _, node := range sub.Nodes {
+		node.Active = true
+	}
+	nodes := expression.Apply(sub.Nodes)
+
+	// 转换时不使用代理集
+	convertCfg := *cfg
+	convertCfg.Output.Clash.ProxyProvider.Enable = false
+	rename := config.RenameConfig{Language: cfg.NodeProcess.Rename.Language}
+	if req.Rename != "" {
+		rename.Enable = true
+		rename.Template = req.Rename
+		rename.Dedupe = true
+	}
+	generator := NewProfileOutputGenerator(&convertCfg, config.ProfileConfig{Name: "convert", Rename: rename})
+
+	if req.Template != "" {
+		template, _, err := fetchURL(req.Template)
+		if err != nil {
+			return "", nil, fmt.Errorf("获取Clash模板失败: %v", err)
+		}
+		generator.clashTemplate = template
+	}
+
+	content, err := generator.Generate(req.Format, nodes)
+	if err != nil {
+		return "", nil, err
+	}
+	return content, sub, nil
+}
+
+// detectSubscriptionType 识别订阅类型，包含proxies列表的YAML或JSON为Clash订阅，其余按分享链接解析
+func detectSubscriptionType(data []byte) string {
+	var clashConfig map[string]interface{}
+	if err := yaml.Unmarshal(data, &clashConfig); err == nil {
+		if _, ok := clashConfig["proxies"].([]interface{}); ok {
+			return "clash"
+		}
+	}
+	return "v2ray"
+}
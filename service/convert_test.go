@@ -0,0 +1,108 @@
+package service
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// TestConvert 订阅内容或地址转换为目标格式，支持过滤、重命名与模板
+func TestConvert(t *testing.T) {
+	clash, err := ioutil.ReadFile(filepath.Join("testdata", "clash", "ss.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	links, err := ioutil.ReadFile(filepath.Join("testdata", "clash", "trojan.uri.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sub":
+			w.Header().Set("subscription-userinfo", "upload=1; download=2; total=30; expire=1900000000")
+			w.Write(clash)
+		case "/template":
+			w.Write([]byte("mode: global\nproxies: \"{{proxies}}\"\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{}
+
+	// 地址中的Clash订阅转换为分享链接，保留来源的流量信息
+	content, sub, err := Convert(cfg, ConvertRequest{URL: server.URL + "/sub", Format: "v2ray"})
+	if err != nil {
+		t.Fatalf("转换失败: %v", err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(strings.Split(string(decoded), "\n")); got != len(clashProxies(t, clash)) {
+		t.Errorf("分享链接数量为 %d", got)
+	}
+	if got := FormatSubscriptionUserinfo([]*model.Subscription{sub}); got != "upload=1; download=2; total=30; expire=1900000000" {
+		t.Errorf("流量信息为 %q", got)
+	}
+
+	// 分享链接转换为Clash配置，过滤并重命名节点，使用远程模板
+	content, _, err = Convert(cfg, ConvertRequest{
+		Content:  base64.StdEncoding.EncodeToString(links),
+		Format:   "clash",
+		Filter:   `name != "trojan-ws"`,
+		Rename:   "T-{名称}",
+		Template: server.URL + "/template",
+	})
+	if err != nil {
+		t.Fatalf("转换失败: %v", err)
+	}
+	if !strings.HasPrefix(content, "mode: global\n") {
+		t.Errorf("未使用指定的模板:\n%s", content)
+	}
+	var names []string
+	for _, proxy := range clashProxies(t, []byte(content)) {
+		names = append(names, proxy["name"].(string))
+	}
+	if strings.Join(names, ",") != "T-trojan-basic,T-trojan-grpc-reality" {
+		t.Errorf("节点为 %v", names)
+	}
+
+	for _, req := range []ConvertRequest{
+		{Content: string(clash), Format: "unknown"},
+		{Format: "clash"},
+		{Content: string(clash), Format: "clash", Filter: "name =="},
+		{URL: server.URL + "/missing", Format: "clash"},
+	} {
+		if _, _, err := Convert(cfg, req); err == nil {
+			t.Errorf("%+v 应转换失败", req)
+		}
+	}
+}
+
+// TestFetchURLLimit 订阅内容超过大小上限时返回错误
+func TestFetchURLLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size := maxSubscriptionSize
+		if r.URL.Path == "/large" {
+			size++
+		}
+		w.Write(make([]byte, size))
+	}))
+	defer server.Close()
+
+	if body, _, err := fetchURL(server.URL + "/limit"); err != nil || len(body) != maxSubscriptionSize {
+		t.Errorf("下载上限大小的内容失败: %d 字节, %v", len(body), err)
+	}
+	if _, _, err := fetchURL(server.URL + "/large"); err == nil {
+		t.Error("超过大小上限时应返回错误")
+	}
+}
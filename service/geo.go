@@ -0,0 +1,415 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// GeoProvider IP地理位置查询提供者
+type GeoProvider interface {
+	// Name 提供者名称，与配置中providers列表的名称对应
+	Name() string
+	// Lookup 查询指定IP的地理位置
+	Lookup(ip string) (*model.IPInfo, error)
+}
+
+// GeoService IP地理位置服务，按顺序依次查询各提供者并缓存结果
+type GeoService struct {
+	cfg       *config.Config
+	providers []GeoProvider
+	cache     map[string]*geoCacheEntry
+	cacheTTL  time.Duration
+	mutex     sync.RWMutex
+}
+
+// maxGeoCache 缓存的IP数量上限，超出时淘汰最早过期的条目
+const maxGeoCache = 4096
+
+// geoCacheEntry 缓存条目
+type geoCacheEntry struct {
+	info    *model.IPInfo
+	expires time.Time
+}
+
+// 默认的提供者顺序，本地数据库未配置时会被跳过
+var defaultGeoProviders = []string{"mmdb", "ip2region", "ipinfo", "ip-api", "ip.sb"}
+
+// 出口IP回显接口
+var outletIPEndpoints = []string{
+	"https://api.ip.sb/ip",
+	"https://api.ipify.org",
+	"https://ipinfo.io/ip",
+}
+
+// NewGeoService 创建IP地理位置服务
+func NewGeoService(cfg *config.Config) *GeoService {
+	ipCfg := cfg.NodeCheck.IPQuality
+
+	timeout := ipCfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 // 默认10秒
+	}
+	httpClient := &http.Client{Timeout: time.Duration(timeout) * time.Second}
+
+	cacheTTL := ipCfg.CacheTTL
+	if cacheTTL <= 0 {
+		cacheTTL = 360 // 默认6小时
+	}
+
+	service := &GeoService{
+		cfg:      cfg,
+		cache:    make(map[string]*geoCacheEntry),
+		cacheTTL: time.Duration(cacheTTL) * time.Minute,
+	}
+
+	names := ipCfg.Providers
+	if len(names) == 0 {
+		names = defaultGeoProviders
+	}
+	for _, name := range names {
+		provider, err := newGeoProvider(name, ipCfg, httpClient)
+		if err != nil {
+			fmt.Printf("初始化IP查询提供者 %s 失败: %v\n", name, err)
+			continue
+		}
+		if provider != nil {
+			service.providers = append(service.providers, provider)
+		}
+	}
+
+	return service
+}
+
+// newGeoProvider 根据名称创建提供者，本地数据库未配置时返回nil
+func newGeoProvider(name string, ipCfg config.IPQualityConfig, httpClient *http.Client) (GeoProvider, error) {
+	switch name {
+	case "mmdb":
+		if ipCfg.MMDBCity == "" && ipCfg.MMDBASN == "" {
+			return nil, nil
+		}
+		return NewMMDBProvider(ipCfg.MMDBCity, ipCfg.MMDBASN)
+	case "ip2region":
+		if ipCfg.IP2Region == "" {
+			return nil, nil
+		}
+		return NewIP2RegionProvider(ipCfg.IP2Region)
+	case "ipinfo":
+		return &ipinfoProvider{client: httpClient, token: ipCfg.IPInfoToken}, nil
+	case "ip-api":
+		return &ipAPIProvider{client: httpClient}, nil
+	case "ip.sb":
+		return &ipSBProvider{client: httpClient}, nil
+	default:
+		return nil, fmt.Errorf("未知的提供者: %s", name)
+	}
+}
+
+// Lookup 查询IP地理位置，依次使用各提供者补全缺失字段，结果按IP缓存
+func (s *GeoService) Lookup(ip string) (*model.IPInfo, error) {
+	if net.ParseIP(ip) == nil {
+		return nil, fmt.Errorf("无效的IP地址: %s", ip)
+	}
+
+	s.mutex.RLock()
+	entry, ok := s.cache[ip]
+	s.mutex.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		info := *entry.info
+		return &info, nil
+	}
+
+	var result *model.IPInfo
+	var lastErr error
+	for _, provider := range s.providers {
+		info, err := provider.Lookup(ip)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %v", provider.Name(), err)
+			continue
+		}
+		if result == nil {
+			result = info
+			result.Source = provider.Name()
+		} else {
+			mergeIPInfo(result, info)
+		}
+		if result.CountryCode != "" && result.ASN != "" {
+			break
+		}
+	}
+
+	if result == nil {
+		if lastErr == nil {
+			lastErr = errors.New("没有可用的IP查询提供者")
+		}
+		return nil, lastErr
+	}
+
+	// 国家名称统一由国家代码生成，避免不同提供者的语言不一致
+	result.IP = ip
+	result.CountryCode = strings.ToUpper(result.CountryCode)
+	if result.CountryCode != "" {
+		result.Country = model.CountryName(result.CountryCode, model.LanguageZh)
+	}
+
+	s.mutex.Lock()
+	if _, ok := s.cache[ip]; !ok && len(s.cache) >= maxGeoCache {
+		s.evictCache(time.Now())
+	}
+	s.cache[ip] = &geoCacheEntry{info: result, expires: time.Now().Add(s.cacheTTL)}
+	s.mutex.Unlock()
+
+	info := *result
+	return &info, nil
+}
+
+// evictCache 清理已过期的缓存，仍然达到上限时淘汰最早过期的条目，调用方需持有写锁
+func (s *GeoService) evictCache(now time.Time) {
+	var oldest string
+	for ip, entry := range s.cache {
+		if !now.Before(entry.expires) {
+			delete(s.cache, ip)
+			continue
+		}
+		if oldest == "" || entry.expires.Before(s.cache[oldest].expires) {
+			oldest = ip
+		}
+	}
+	if len(s.cache) >= maxGeoCache {
+		delete(s.cache, oldest)
+	}
+}
+
+// mergeIPInfo 使用src补全dst中的空字段
+func mergeIPInfo(dst, src *model.IPInfo) {
+	if dst.CountryCode == "" {
+		dst.CountryCode = src.CountryCode
+		dst.Country = src.Country
+	}
+	if dst.Region == "" {
+		dst.Region = src.Region
+	}
+	if dst.City == "" {
+		dst.City = src.City
+	}
+	if dst.ISP == "" {
+		dst.ISP = src.ISP
+	}
+	if dst.ASN == "" {
+		dst.ASN = src.ASN
+	}
+	if dst.Org == "" {
+		dst.Org = src.Org
+	}
+	if dst.Lat == 0 && dst.Lon == 0 {
+		dst.Lat, dst.Lon = src.Lat, src.Lon
+	}
+	if dst.TimeZone == "" {
+		dst.TimeZone = src.TimeZone
+	}
+}
+
+// DetectOutletIP 通过节点代理获取出口IP
+func DetectOutletIP(client *http.Client) (string, error) {
+	var lastErr error
+	for _, endpoint := range outletIPEndpoints {
+		body, err := getBody(client, endpoint)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		ip := strings.TrimSpace(string(body))
+		if net.ParseIP(ip) != nil {
+			return ip, nil
+		}
+		lastErr = fmt.Errorf("%s 返回了无效的IP: %q", endpoint, ip)
+	}
+	return "", lastErr
+}
+
+// getBody 发送GET请求并读取响应体
+func getBody(client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "ShareSubWeb/1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP请求错误: %d", resp.StatusCode)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// ipinfoProvider ipinfo.io查询
+type ipinfoProvider struct {
+	client *http.Client
+	token  string
+}
+
+func (p *ipinfoProvider) Name() string { return "ipinfo" }
+
+func (p *ipinfoProvider) Lookup(ip string) (*model.IPInfo, error) {
+	url := fmt.Sprintf("https://ipinfo.io/%s/json", ip)
+	if p.token != "" {
+		url += "?token=" + p.token
+	}
+
+	body, err := getBody(p.client, url)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Country  string `json:"country"`
+		Region   string `json:"region"`
+		City     string `json:"city"`
+		Org      string `json:"org"`
+		Loc      string `json:"loc"`
+		Timezone string `json:"timezone"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+
+	info := &model.IPInfo{
+		CountryCode: resp.Country,
+		Region:      resp.Region,
+		City:        resp.City,
+		Org:         resp.Org,
+		TimeZone:    resp.Timezone,
+	}
+
+	// 通常org格式为 "AS13335 Cloudflare, Inc."
+	parts := strings.SplitN(resp.Org, " ", 2)
+	if len(parts) == 2 && strings.HasPrefix(parts[0], "AS") {
+		info.ASN = parts[0]
+		info.ISP = parts[1]
+	} else {
+		info.ISP = resp.Org
+	}
+
+	fmt.Sscanf(resp.Loc, "%f,%f", &info.Lat, &info.Lon)
+	return info, nil
+}
+
+// IPLookupResponse IP查询响应
+type IPLookupResponse struct {
+	Status      string  `json:"status"`
+	Message     string  `json:"message"`
+	Country     string  `json:"country"`
+	CountryCode string  `json:"countryCode"`
+	Region      string  `json:"region"`
+	RegionName  string  `json:"regionName"`
+	City        string  `json:"city"`
+	Zip         string  `json:"zip"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+	Timezone    string  `json:"timezone"`
+	ISP         string  `json:"isp"`
+	Org         string  `json:"org"`
+	AS          string  `json:"as"`
+	Query       string  `json:"query"`
+}
+
+// ipAPIProvider ip-api.com查询
+type ipAPIProvider struct {
+	client *http.Client
+}
+
+func (p *ipAPIProvider) Name() string { return "ip-api" }
+
+func (p *ipAPIProvider) Lookup(ip string) (*model.IPInfo, error) {
+	body, err := getBody(p.client, fmt.Sprintf("http://ip-api.com/json/%s", ip))
+	if err != nil {
+		return nil, err
+	}
+
+	var resp IPLookupResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "success" {
+		return nil, fmt.Errorf("查询失败: %s", resp.Message)
+	}
+
+	info := &model.IPInfo{
+		Country:     resp.Country,
+		CountryCode: resp.CountryCode,
+		Region:      resp.RegionName,
+		City:        resp.City,
+		ISP:         resp.ISP,
+		Org:         resp.Org,
+		Lat:         resp.Lat,
+		Lon:         resp.Lon,
+		TimeZone:    resp.Timezone,
+	}
+
+	// as格式为 "AS13335 Cloudflare, Inc."
+	if asn := strings.SplitN(resp.AS, " ", 2)[0]; strings.HasPrefix(asn, "AS") {
+		info.ASN = asn
+	}
+	return info, nil
+}
+
+// ipSBProvider ip.sb查询
+type ipSBProvider struct {
+	client *http.Client
+}
+
+func (p *ipSBProvider) Name() string { return "ip.sb" }
+
+func (p *ipSBProvider) Lookup(ip string) (*model.IPInfo, error) {
+	body, err := getBody(p.client, fmt.Sprintf("https://api.ip.sb/geoip/%s", ip))
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		CountryCode  string  `json:"country_code"`
+		Country      string  `json:"country"`
+		Region       string  `json:"region"`
+		City         string  `json:"city"`
+		ISP          string  `json:"isp"`
+		Organization string  `json:"organization"`
+		ASN          int     `json:"asn"`
+		Latitude     float64 `json:"latitude"`
+		Longitude    float64 `json:"longitude"`
+		Timezone     string  `json:"timezone"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+
+	info := &model.IPInfo{
+		Country:     resp.Country,
+		CountryCode: resp.CountryCode,
+		Region:      resp.Region,
+		City:        resp.City,
+		ISP:         resp.ISP,
+		Org:         resp.Organization,
+		Lat:         resp.Latitude,
+		Lon:         resp.Longitude,
+		TimeZone:    resp.Timezone,
+	}
+	if resp.ASN > 0 {
+		info.ASN = fmt.Sprintf("AS%d", resp.ASN)
+	}
+	return info, nil
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"github.com/nariahlamb/sharesubweb/model"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// mmdbProvider 本地MaxMind数据库查询（GeoLite2 City/ASN）
+type mmdbProvider struct {
+	city *maxminddb.Reader
+	asn  *maxminddb.Reader
+}
+
+// mmdbCityRecord GeoLite2-City记录
+type mmdbCityRecord struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+		TimeZone  string  `maxminddb:"time_zone"`
+	} `maxminddb:"location"`
+}
+
+// mmdbASNRecord GeoLite2-ASN记录
+type mmdbASNRecord struct {
+	Number       uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// NewMMDBProvider 打开本地MaxMind数据库，city与asn可只配置其一
+func NewMMDBProvider(cityFile, asnFile string) (GeoProvider, error) {
+	provider := &mmdbProvider{}
+
+	var err error
+	if cityFile != "" {
+		if provider.city, err = maxminddb.Open(cityFile); err != nil {
+			return nil, fmt.Errorf("打开City数据库失败: %v", err)
+		}
+	}
+	if asnFile != "" {
+		if provider.asn, err = maxminddb.Open(asnFile); err != nil {
+			return nil, fmt.Errorf("打开ASN数据库失败: %v", err)
+		}
+	}
+
+	return provider, nil
+}
+
+func (p *mmdbProvider) Name() string { return "mmdb" }
+
+func (p *mmdbProvider) Lookup(ip string) (*model.IPInfo, error) {
+	addr := net.ParseIP(ip)
+	info := &model.IPInfo{}
+
+	if p.city != nil {
+		var record mmdbCityRecord
+		if err := p.city.Lookup(addr, &record); err != nil {
+			return nil, err
+		}
+		info.CountryCode = record.Country.ISOCode
+		info.City = localizedName(record.City.Names)
+		if len(record.Subdivisions) > 0 {
+			info.Region = localizedName(record.Subdivisions[0].Names)
+		}
+		info.Lat = record.Location.Latitude
+		info.Lon = record.Location.Longitude
+		info.TimeZone = record.Location.TimeZone
+	}
+
+	if p.asn != nil {
+		var record mmdbASNRecord
+		if err := p.asn.Lookup(addr, &record); err != nil {
+			return nil, err
+		}
+		if record.Number > 0 {
+			info.ASN = fmt.Sprintf("AS%d", record.Number)
+		}
+		info.ISP = record.Organization
+		info.Org = record.Organization
+	}
+
+	if info.CountryCode == "" && info.ASN == "" {
+		return nil, errors.New("数据库中没有该IP的记录")
+	}
+	return info, nil
+}
+
+// localizedName 优先使用中文名称
+func localizedName(names map[string]string) string {
+	if name, ok := names["zh-CN"]; ok {
+		return name
+	}
+	return names["en"]
+}
+
+// ip2regionProvider 本地ip2region xdb数据库查询，仅支持IPv4
+type ip2regionProvider struct {
+	content []byte
+}
+
+// ip2region xdb文件布局
+const (
+	xdbHeaderLength      = 256
+	xdbVectorIndexCols   = 256
+	xdbVectorIndexSize   = 8
+	xdbSegmentIndexSize  = 14
+	xdbVectorIndexLength = 256 * 256 * xdbVectorIndexSize
+)
+
+// NewIP2RegionProvider 将ip2region xdb文件整体加载到内存
+func NewIP2RegionProvider(file string) (GeoProvider, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("读取ip2region数据库失败: %v", err)
+	}
+	if len(content) < xdbHeaderLength+xdbVectorIndexLength {
+		return nil, errors.New("ip2region数据库文件不完整")
+	}
+	return &ip2regionProvider{content: content}, nil
+}
+
+func (p *ip2regionProvider) Name() string { return "ip2region" }
+
+func (p *ip2regionProvider) Lookup(ip string) (*model.IPInfo, error) {
+	addr := net.ParseIP(ip).To4()
+	if addr == nil {
+		return nil, errors.New("ip2region仅支持IPv4")
+	}
+
+	region, err := p.search(binary.BigEndian.Uint32(addr))
+	if err != nil {
+		return nil, err
+	}
+
+	// 区域格式为 国家|区域|省份|城市|ISP，未知字段为0
+	fields := strings.Split(region, "|")
+	for len(fields) < 5 {
+		fields = append(fields, "0")
+	}
+	for i, field := range fields {
+		if field == "0" {
+			fields[i] = ""
+		}
+	}
+
+	info := &model.IPInfo{
+		Country: fields[0],
+		Region:  fields[2],
+		City:    fields[3],
+		ISP:     fields[4],
+	}
+	info.CountryCode = model.CountryCodeByName(info.Country)
+	// ip2region将港澳台记录为中国下的省份
+	if info.CountryCode == "CN" {
+		if code := model.CountryCodeByName(info.Region); code == "HK" || code == "MO" || code == "TW" {
+			info.CountryCode = code
+		}
+	}
+	if info.CountryCode == "" {
+		return nil, fmt.Errorf("无法识别的国家: %s", info.Country)
+	}
+	return info, nil
+}
+
+// search 在xdb中二分查找IP所在的区段
+func (p *ip2regionProvider) search(ip uint32) (string, error) {
+	il0 := ip >> 24 & 0xFF
+	il1 := ip >> 16 & 0xFF
+	offset := xdbHeaderLength + int(il0*xdbVectorIndexCols*xdbVectorIndexSize+il1*xdbVectorIndexSize)
+	sPtr := binary.LittleEndian.Uint32(p.content[offset:])
+	ePtr := binary.LittleEndian.Uint32(p.content[offset+4:])
+	if sPtr == 0 || ePtr < sPtr {
+		return "", errors.New("数据库中没有该IP的记录")
+	}
+
+	low, high := 0, int((ePtr-sPtr)/xdbSegmentIndexSize)
+	for low <= high {
+		mid := (low + high) / 2
+		pos := int(sPtr) + mid*xdbSegmentIndexSize
+		if pos+xdbSegmentIndexSize > len(p.content) {
+			break
+		}
+
+		startIP := binary.LittleEndian.Uint32(p.content[pos:])
+		endIP := binary.LittleEndian.Uint32(p.content[pos+4:])
+		switch {
+		case ip < startIP:
+			high = mid - 1
+		case ip > endIP:
+			low = mid + 1
+		default:
+			dataLen := int(binary.LittleEndian.Uint16(p.content[pos+8:]))
+			dataPtr := int(binary.LittleEndian.Uint32(p.content[pos+10:]))
+			if dataPtr+dataLen > len(p.content) {
+				return "", errors.New("ip2region数据库损坏")
+			}
+			return string(p.content[dataPtr : dataPtr+dataLen]), nil
+		}
+	}
+
+	return "", errors.New("数据库中没有该IP的记录")
+}
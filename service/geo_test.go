@@ -0,0 +1,236 @@
+package service
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// xdbSegment ip2region区段，测试中每个区段位于同一个/16内
+type xdbSegment struct {
+	start, end string
+	region     string
+}
+
+// writeXDB 按ip2region xdb布局生成测试数据库：头部、向量索引、区段索引与区域数据
+func writeXDB(t *testing.T, segments []xdbSegment) string {
+	t.Helper()
+	indexStart := xdbHeaderLength + xdbVectorIndexLength
+	dataStart := indexStart + len(segments)*xdbSegmentIndexSize
+	content := make([]byte, dataStart)
+
+	var data []byte
+	for i, segment := range segments {
+		start := binary.BigEndian.Uint32(net.ParseIP(segment.start).To4())
+		end := binary.BigEndian.Uint32(net.ParseIP(segment.end).To4())
+		pos := indexStart + i*xdbSegmentIndexSize
+		binary.LittleEndian.PutUint32(content[pos:], start)
+		binary.LittleEndian.PutUint32(content[pos+4:], end)
+		binary.LittleEndian.PutUint16(content[pos+8:], uint16(len(segment.region)))
+		binary.LittleEndian.PutUint32(content[pos+10:], uint32(dataStart+len(data)))
+		data = append(data, segment.region...)
+
+		// 向量索引记录该/16内第一个与最后一个区段的位置
+		vector := xdbHeaderLength + int(start>>24&0xFF)*xdbVectorIndexCols*xdbVectorIndexSize + int(start>>16&0xFF)*xdbVectorIndexSize
+		if binary.LittleEndian.Uint32(content[vector:]) == 0 {
+			binary.LittleEndian.PutUint32(content[vector:], uint32(pos))
+		}
+		binary.LittleEndian.PutUint32(content[vector+4:], uint32(pos))
+	}
+
+	file := filepath.Join(t.TempDir(), "ip2region.xdb")
+	if err := os.WriteFile(file, append(content, data...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// TestIP2RegionLookup 在区段索引中查找IP，港澳台记录为对应的国家代码，未知字段为空
+func TestIP2RegionLookup(t *testing.T) {
+	provider, err := NewIP2RegionProvider(writeXDB(t, []xdbSegment{
+		{"1.0.0.0", "1.0.0.255", "中国|0|广东省|深圳市|电信"},
+		{"1.0.1.0", "1.0.3.255", "中国|0|香港|0|0"},
+		{"1.0.4.0", "1.0.255.255", "日本|0|东京都|东京|0"},
+		{"8.8.8.0", "8.8.8.255", "火星|0|0|0|0"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip   string
+		want *model.IPInfo
+	}{
+		{"1.0.0.1", &model.IPInfo{Country: "中国", CountryCode: "CN", Region: "广东省", City: "深圳市", ISP: "电信"}},
+		{"1.0.0.255", &model.IPInfo{Country: "中国", CountryCode: "CN", Region: "广东省", City: "深圳市", ISP: "电信"}},
+		{"1.0.2.3", &model.IPInfo{Country: "中国", CountryCode: "HK", Region: "香港"}},
+		{"1.0.200.1", &model.IPInfo{Country: "日本", CountryCode: "JP", Region: "东京都", City: "东京"}},
+	}
+	for _, test := range tests {
+		got, err := provider.Lookup(test.ip)
+		if err != nil {
+			t.Errorf("查询 %s 失败: %v", test.ip, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("查询 %s 结果为 %+v，期望 %+v", test.ip, got, test.want)
+		}
+	}
+
+	// 无法识别的国家、没有记录的区段与IPv6均返回错误
+	for _, ip := range []string{"8.8.8.8", "8.8.9.1", "9.9.9.9", "2001:db8::1"} {
+		if info, err := provider.Lookup(ip); err == nil {
+			t.Errorf("查询 %s 应失败，得到 %+v", ip, info)
+		}
+	}
+
+	if _, err := NewIP2RegionProvider(filepath.Join(t.TempDir(), "missing.xdb")); err == nil {
+		t.Error("数据库文件不存在时应返回错误")
+	}
+	short := filepath.Join(t.TempDir(), "short.xdb")
+	if err := os.WriteFile(short, make([]byte, xdbHeaderLength), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewIP2RegionProvider(short); err == nil {
+		t.Error("数据库文件不完整时应返回错误")
+	}
+}
+
+// TestMergeIPInfo 只补全空字段，国家代码与国家名称一起补全
+func TestMergeIPInfo(t *testing.T) {
+	dst := &model.IPInfo{CountryCode: "JP", Country: "日本", City: "东京", Lat: 35.6, Lon: 139.7}
+	src := &model.IPInfo{
+		CountryCode: "US", Country: "United States", Region: "Tokyo", City: "Tokyo",
+		ISP: "IIJ", ASN: "AS2497", Org: "Internet Initiative Japan", Lat: 1, Lon: 2, TimeZone: "Asia/Tokyo",
+	}
+	mergeIPInfo(dst, src)
+
+	want := &model.IPInfo{
+		CountryCode: "JP", Country: "日本", Region: "Tokyo", City: "东京",
+		ISP: "IIJ", ASN: "AS2497", Org: "Internet Initiative Japan", Lat: 35.6, Lon: 139.7, TimeZone: "Asia/Tokyo",
+	}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("合并结果为 %+v，期望 %+v", dst, want)
+	}
+
+	empty := &model.IPInfo{}
+	mergeIPInfo(empty, src)
+	if empty.CountryCode != "US" || empty.Country != "United States" || empty.Lat != 1 || empty.Lon != 2 {
+		t.Errorf("补全空信息的结果为 %+v", empty)
+	}
+}
+
+// fakeGeoProvider 返回固定结果并记录查询次数
+type fakeGeoProvider struct {
+	name  string
+	info  *model.IPInfo
+	calls int
+}
+
+func (p *fakeGeoProvider) Name() string { return p.name }
+
+func (p *fakeGeoProvider) Lookup(ip string) (*model.IPInfo, error) {
+	p.calls++
+	if p.info == nil {
+		return nil, errors.New("没有记录")
+	}
+	info := *p.info
+	return &info, nil
+}
+
+// TestGeoLookup 依次查询各提供者补全缺失字段，国家与ASN齐全后不再查询，结果在缓存有效期内复用
+func TestGeoLookup(t *testing.T) {
+	failing := &fakeGeoProvider{name: "failing"}
+	country := &fakeGeoProvider{name: "country", info: &model.IPInfo{CountryCode: "jp", Country: "Japan"}}
+	asn := &fakeGeoProvider{name: "asn", info: &model.IPInfo{CountryCode: "US", ASN: "AS2497", ISP: "IIJ"}}
+	unused := &fakeGeoProvider{name: "unused", info: &model.IPInfo{City: "Osaka"}}
+
+	s := NewGeoService(&config.Config{})
+	s.providers = []GeoProvider{failing, country, asn, unused}
+
+	info, err := s.Lookup("192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &model.IPInfo{IP: "192.0.2.1", CountryCode: "JP", Country: model.CountryName("JP", model.LanguageZh), ASN: "AS2497", ISP: "IIJ", Source: "country"}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("查询结果为 %+v，期望 %+v", info, want)
+	}
+	if unused.calls != 0 {
+		t.Error("国家与ASN齐全后不应继续查询")
+	}
+
+	// 返回的是缓存的副本
+	info.City = "changed"
+	if _, err := s.Lookup("192.0.2.1"); err != nil || country.calls != 1 {
+		t.Errorf("缓存有效期内不应再次查询，查询次数 %d, %v", country.calls, err)
+	}
+	if cached, _ := s.Lookup("192.0.2.1"); cached.City != "" {
+		t.Error("修改返回结果不应影响缓存")
+	}
+
+	// 缓存过期后重新查询
+	s.cache["192.0.2.1"].expires = time.Now().Add(-time.Second)
+	if _, err := s.Lookup("192.0.2.1"); err != nil || country.calls != 2 {
+		t.Errorf("缓存过期后应重新查询，查询次数 %d, %v", country.calls, err)
+	}
+
+	// 所有提供者均失败时返回最后一个错误
+	s.providers = []GeoProvider{failing}
+	if _, err := s.Lookup("192.0.2.2"); err == nil {
+		t.Error("所有提供者均失败时应返回错误")
+	}
+	if _, err := s.Lookup("not-an-ip"); err == nil {
+		t.Error("无效的IP地址应返回错误")
+	}
+}
+
+// TestGeoCacheEvict 达到上限时先清理过期条目，仍然已满时淘汰最早过期的条目
+func TestGeoCacheEvict(t *testing.T) {
+	s := NewGeoService(&config.Config{})
+	now := time.Now()
+	for i := 0; i < maxGeoCache; i++ {
+		s.cache[fmt.Sprintf("10.0.%d.%d", i/256, i%256)] = &geoCacheEntry{
+			info:    &model.IPInfo{},
+			expires: now.Add(time.Duration(i+1) * time.Minute),
+		}
+	}
+	s.cache["10.0.0.0"].expires = now.Add(-time.Minute)
+
+	s.evictCache(now)
+	if len(s.cache) != maxGeoCache-1 {
+		t.Fatalf("清理过期条目后剩余 %d 个，期望 %d", len(s.cache), maxGeoCache-1)
+	}
+	if _, ok := s.cache["10.0.0.0"]; ok {
+		t.Error("过期条目未被清理")
+	}
+
+	s.cache["10.0.0.0"] = &geoCacheEntry{info: &model.IPInfo{}, expires: now.Add(time.Hour)}
+	s.evictCache(now)
+	if len(s.cache) != maxGeoCache-1 {
+		t.Fatalf("淘汰后剩余 %d 个，期望 %d", len(s.cache), maxGeoCache-1)
+	}
+	if _, ok := s.cache["10.0.0.1"]; ok {
+		t.Error("最早过期的条目未被淘汰")
+	}
+
+	// 写入新IP时缓存大小不超过上限
+	s.providers = []GeoProvider{&fakeGeoProvider{name: "fake", info: &model.IPInfo{CountryCode: "JP", ASN: "AS1"}}}
+	for i := 0; i < 3; i++ {
+		if _, err := s.Lookup(fmt.Sprintf("192.0.2.%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.cache) > maxGeoCache {
+		t.Errorf("缓存大小为 %d，超过上限 %d", len(s.cache), maxGeoCache)
+	}
+}
@@ -0,0 +1,390 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// IP类型
+const (
+	IPTypeHosting     = "hosting"     // 机房
+	IPTypeResidential = "residential" // 家宽
+	IPTypeMobile      = "mobile"      // 移动网络
+	IPTypeUnknown     = "unknown"     // 未知
+)
+
+// 默认的黑名单与DNSBL配置
+var (
+	defaultBanLists   = []string{"./ip/ban.txt", "./ip/banv6.txt"}
+	defaultUnbanLists = []string{"./ip/unban.txt", "./ip/unbanv6.txt"}
+	defaultDNSBL      = []string{"zen.spamhaus.org", "bl.spamcop.net", "b.barracudacentral.org"}
+)
+
+// 常见机房与移动网络ASN
+var (
+	hostingASNs = map[string]bool{
+		"AS13335": true, "AS16509": true, "AS14618": true, "AS15169": true, "AS396982": true,
+		"AS8075": true, "AS14061": true, "AS20473": true, "AS63949": true, "AS24940": true,
+		"AS16276": true, "AS45102": true, "AS37963": true, "AS132203": true, "AS45090": true,
+		"AS31898": true, "AS9009": true, "AS60068": true, "AS212238": true, "AS62240": true,
+		"AS36352": true, "AS35916": true, "AS25820": true, "AS40065": true, "AS906": true,
+		"AS54600": true, "AS21859": true, "AS3258": true, "AS51167": true, "AS8560": true,
+		"AS136907": true, "AS55990": true, "AS199524": true, "AS202422": true, "AS49981": true,
+	}
+	mobileASNs = map[string]bool{
+		"AS9808": true, "AS24445": true, "AS56040": true, "AS56041": true, "AS56046": true,
+		"AS21928": true, "AS22394": true, "AS20057": true, "AS9644": true, "AS24203": true,
+	}
+	hostingKeywords = []string{
+		"hosting", "host", "cloud", "data center", "datacenter", "server", "vps", "colo",
+		"dedicated", "cdn", "amazon", "google", "microsoft", "digitalocean", "vultr", "choopa",
+		"linode", "akamai", "hetzner", "ovh", "alibaba", "aliyun", "tencent", "oracle", "m247",
+		"leaseweb", "contabo", "zenlayer", "dmit", "it7", "cloudflare", "gcore", "byteplus",
+	}
+	mobileKeywords = []string{
+		"mobile", "wireless", "cellular", "lte", "5g", "mobility",
+	}
+	residentialKeywords = []string{
+		"telecom", "broadband", "cable", "communications", "telekom", "comcast", "charter",
+		"verizon", "at&t", "ntt", "kddi", "softbank", "hinet", "chunghwa", "pccw", "hkbn",
+		"singtel", "starhub", "unicom", "netvigator", "spectrum", "fiber", "dsl", "internet service",
+	}
+)
+
+// maxIPQualityCache 缓存的IP数量上限，超出时淘汰最早过期的条目
+const maxIPQualityCache = 4096
+
+// IPQualityService IP质量评估服务
+type IPQualityService struct {
+	cfg        *config.Config
+	banList    *cidrList
+	unbanList  *cidrList
+	dnsbl      []string
+	httpClient *http.Client
+	cache      map[string]*ipQualityCacheEntry
+	cacheTTL   time.Duration
+	mutex      sync.RWMutex
+}
+
+// ipQualityCacheEntry 缓存条目
+type ipQualityCacheEntry struct {
+	quality *model.IPQuality
+	expires time.Time
+}
+
+// NewIPQualityService 创建IP质量评估服务
+func NewIPQualityService(cfg *config.Config) *IPQualityService {
+	ipCfg := cfg.NodeCheck.IPQuality
+
+	banLists := ipCfg.BanLists
+	if len(banLists) == 0 {
+		banLists = defaultBanLists
+	}
+	unbanLists := ipCfg.UnbanLists
+	if len(unbanLists) == 0 {
+		unbanLists = defaultUnbanLists
+	}
+	dnsbl := ipCfg.DNSBL
+	if len(dnsbl) == 0 {
+		dnsbl = defaultDNSBL
+	}
+
+	timeout := ipCfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 // 默认10秒
+	}
+
+	cacheTTL := ipCfg.CacheTTL
+	if cacheTTL <= 0 {
+		cacheTTL = 360 // 默认6小时
+	}
+
+	return &IPQualityService{
+		cfg:        cfg,
+		banList:    loadCIDRLists(banLists),
+		unbanList:  loadCIDRLists(unbanLists),
+		dnsbl:      dnsbl,
+		httpClient: &http.Client{Timeout: time.Duration(timeout) * time.Second},
+		cache:      make(map[string]*ipQualityCacheEntry),
+		cacheTTL:   time.Duration(cacheTTL) * time.Minute,
+	}
+}
+
+// Evaluate 评估IP质量，结果按IP缓存
+func (s *IPQualityService) Evaluate(info *model.IPInfo) *model.IPQuality {
+	if info == nil || info.IP == "" {
+		return nil
+	}
+
+	s.mutex.RLock()
+	entry, ok := s.cache[info.IP]
+	s.mutex.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		quality := *entry.quality
+		return &quality
+	}
+
+	ip := net.ParseIP(info.IP)
+	quality := &model.IPQuality{
+		Type: classifyIPType(info),
+	}
+
+	// ip-api提供的代理/机房/移动网络标记
+	if s.cfg.NodeCheck.IPQuality.ProxyDetect {
+		if flags, err := s.lookupProxyFlags(info.IP); err == nil {
+			quality.Proxy = flags.Proxy
+			switch {
+			case flags.Mobile:
+				quality.Type = IPTypeMobile
+			case flags.Hosting:
+				quality.Type = IPTypeHosting
+			}
+		}
+	}
+
+	quality.Banned = s.banList.contains(ip) && !s.unbanList.contains(ip)
+	quality.Blacklists = s.checkDNSBL(ip)
+	quality.Score = scoreIPQuality(quality)
+
+	s.mutex.Lock()
+	if _, ok := s.cache[info.IP]; !ok && len(s.cache) >= maxIPQualityCache {
+		s.evictCache(time.Now())
+	}
+	s.cache[info.IP] = &ipQualityCacheEntry{quality: quality, expires: time.Now().Add(s.cacheTTL)}
+	s.mutex.Unlock()
+
+	result := *quality
+	return &result
+}
+
+// evictCache 清理已过期的缓存，仍然达到上限时淘汰最早过期的条目，调用方需持有写锁
+func (s *IPQualityService) evictCache(now time.Time) {
+	var oldest string
+	for ip, entry := range s.cache {
+		if !now.Before(entry.expires) {
+			delete(s.cache, ip)
+			continue
+		}
+		if oldest == "" || entry.expires.Before(s.cache[oldest].expires) {
+			oldest = ip
+		}
+	}
+	if len(s.cache) >= maxIPQualityCache {
+		delete(s.cache, oldest)
+	}
+}
+
+// classifyIPType 根据ASN与运营商名称判断IP类型
+func classifyIPType(info *model.IPInfo) string {
+	if hostingASNs[info.ASN] {
+		return IPTypeHosting
+	}
+	if mobileASNs[info.ASN] {
+		return IPTypeMobile
+	}
+
+	name := strings.ToLower(info.ISP + " " + info.Org)
+	for _, keyword := range mobileKeywords {
+		if strings.Contains(name, keyword) {
+			return IPTypeMobile
+		}
+	}
+	for _, keyword := range hostingKeywords {
+		if strings.Contains(name, keyword) {
+			return IPTypeHosting
+		}
+	}
+	for _, keyword := range residentialKeywords {
+		if strings.Contains(name, keyword) {
+			return IPTypeResidential
+		}
+	}
+	return IPTypeUnknown
+}
+
+// scoreIPQuality 计算综合评分，满分100，越高越干净
+func scoreIPQuality(quality *model.IPQuality) int {
+	score := 100
+	switch quality.Type {
+	case IPTypeHosting:
+		score -= 25
+	case IPTypeUnknown:
+		score -= 5
+	}
+	if quality.Proxy {
+		score -= 30
+	}
+	if quality.Banned {
+		score -= 40
+	}
+	score -= 15 * len(quality.Blacklists)
+
+	if score < 0 {
+		score = 0
+	}
+	return score
+}
+
+// proxyFlags ip-api的代理检测字段
+type proxyFlags struct {
+	Status  string `json:"status"`
+	Mobile  bool   `json:"mobile"`
+	Proxy   bool   `json:"proxy"`
+	Hosting bool   `json:"hosting"`
+}
+
+// lookupProxyFlags 查询ip-api的代理/机房/移动网络标记
+func (s *IPQualityService) lookupProxyFlags(ip string) (*proxyFlags, error) {
+	body, err := getBody(s.httpClient, fmt.Sprintf("http://ip-api.com/json/%s?fields=status,mobile,proxy,hosting", ip))
+	if err != nil {
+		return nil, err
+	}
+
+	var flags proxyFlags
+	if err := json.Unmarshal(body, &flags); err != nil {
+		return nil, err
+	}
+	if flags.Status != "success" {
+		return nil, fmt.Errorf("查询失败: %s", ip)
+	}
+	return &flags, nil
+}
+
+// checkDNSBL 查询DNSBL黑名单，返回命中的列表，仅支持IPv4
+func (s *IPQualityService) checkDNSBL(ip net.IP) []string {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return nil
+	}
+
+	reversed := fmt.Sprintf("%d.%d.%d.%d", ip4[3], ip4[2], ip4[1], ip4[0])
+	listed := make([]string, 0)
+	for _, zone := range s.dnsbl {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		addrs, err := net.DefaultResolver.LookupHost(ctx, reversed+"."+zone)
+		cancel()
+		if err != nil {
+			continue
+		}
+
+		// 仅127.0.0.0/8的返回值表示命中，其他返回值通常是查询被拒绝
+		for _, addr := range addrs {
+			if strings.HasPrefix(addr, "127.0.0.") && addr != "127.255.255.254" {
+				listed = append(listed, zone)
+				break
+			}
+		}
+	}
+	return listed
+}
+
+// cidrList 已排序合并的IP区间列表，用于快速匹配
+type cidrList struct {
+	ranges []ipRange
+}
+
+// ipRange IP区间，统一为16字节表示
+type ipRange struct {
+	start net.IP
+	end   net.IP
+}
+
+// loadCIDRLists 加载CIDR列表文件，每行一个CIDR或单个IP，无效行会被忽略
+func loadCIDRLists(files []string) *cidrList {
+	list := &cidrList{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				fmt.Printf("读取IP列表 %s 失败: %v\n", file, err)
+			}
+			continue
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			if r, ok := parseIPRange(strings.TrimSpace(scanner.Text())); ok {
+				list.ranges = append(list.ranges, r)
+			}
+		}
+	}
+
+	list.normalize()
+	return list
+}
+
+// parseIPRange 解析CIDR或单个IP
+func parseIPRange(line string) (ipRange, bool) {
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ipRange{}, false
+	}
+
+	if !strings.Contains(line, "/") {
+		ip := net.ParseIP(line)
+		if ip == nil {
+			return ipRange{}, false
+		}
+		return ipRange{start: ip.To16(), end: ip.To16()}, true
+	}
+
+	_, network, err := net.ParseCIDR(line)
+	if err != nil {
+		return ipRange{}, false
+	}
+
+	start := network.IP.To16()
+	end := make(net.IP, net.IPv6len)
+	copy(end, start)
+	mask := network.Mask
+	offset := net.IPv6len - len(mask)
+	for i := range mask {
+		end[offset+i] |= ^mask[i]
+	}
+	return ipRange{start: start, end: end}, true
+}
+
+// normalize 排序并合并重叠区间
+func (l *cidrList) normalize() {
+	sort.Slice(l.ranges, func(i, j int) bool {
+		return bytes.Compare(l.ranges[i].start, l.ranges[j].start) < 0
+	})
+
+	merged := make([]ipRange, 0, len(l.ranges))
+	for _, r := range l.ranges {
+		last := len(merged) - 1
+		if last >= 0 && bytes.Compare(r.start, merged[last].end) <= 0 {
+			if bytes.Compare(r.end, merged[last].end) > 0 {
+				merged[last].end = r.end
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	l.ranges = merged
+}
+
+// contains 判断IP是否在列表中
+func (l *cidrList) contains(ip net.IP) bool {
+	if l == nil || ip == nil {
+		return false
+	}
+
+	ip = ip.To16()
+	i := sort.Search(len(l.ranges), func(i int) bool {
+		return bytes.Compare(l.ranges[i].end, ip) >= 0
+	})
+	return i < len(l.ranges) && bytes.Compare(l.ranges[i].start, ip) <= 0
+}
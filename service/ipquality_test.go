@@ -0,0 +1,40 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// TestIPQualityCacheEvict 达到上限时先清理过期条目，仍然已满时淘汰最早过期的条目
+func TestIPQualityCacheEvict(t *testing.T) {
+	s := NewIPQualityService(&config.Config{})
+	now := time.Now()
+	for i := 0; i < maxIPQualityCache; i++ {
+		s.cache[fmt.Sprintf("10.0.%d.%d", i/256, i%256)] = &ipQualityCacheEntry{
+			quality: &model.IPQuality{},
+			expires: now.Add(time.Duration(i+1) * time.Minute),
+		}
+	}
+	s.cache["10.0.0.0"].expires = now.Add(-time.Minute)
+
+	s.evictCache(now)
+	if len(s.cache) != maxIPQualityCache-1 {
+		t.Fatalf("清理过期条目后剩余 %d 个，期望 %d", len(s.cache), maxIPQualityCache-1)
+	}
+	if _, ok := s.cache["10.0.0.0"]; ok {
+		t.Error("过期条目未被清理")
+	}
+
+	s.cache["10.0.0.0"] = &ipQualityCacheEntry{quality: &model.IPQuality{}, expires: now.Add(time.Hour)}
+	s.evictCache(now)
+	if len(s.cache) != maxIPQualityCache-1 {
+		t.Fatalf("淘汰后剩余 %d 个，期望 %d", len(s.cache), maxIPQualityCache-1)
+	}
+	if _, ok := s.cache["10.0.0.1"]; ok {
+		t.Error("最早过期的条目未被淘汰")
+	}
+}
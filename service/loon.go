@@ -0,0 +1,225 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// loonGeneral Loon的[General]配置
+const loonGeneral = `[General]
+ipv6 = false
+skip-proxy = 127.0.0.1,192.168.0.0/16,10.0.0.0/8,172.16.0.0/12,100.64.0.0/10,localhost,*.local,e.crashlytics.com,captive.apple.com
+bypass-tun = 10.0.0.0/8,100.64.0.0/10,127.0.0.0/8,169.254.0.0/16,172.16.0.0/12,192.0.0.0/24,192.0.2.0/24,192.88.99.0/24,192.168.0.0/16,198.18.0.0/15,198.51.100.0/24,203.0.113.0/24,224.0.0.0/4,255.255.255.255/32
+dns-server = system,119.29.29.29,223.5.5.5
+`
+
+// GenerateLoonConfig 生成Loon配置
+func (g *OutputGenerator) GenerateLoonConfig(nodes []*model.ProxyNode) (string, error) {
+	client, err := g.buildClientConfig(nodes, loonProxy)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# ShareSubWeb Subscription\n\n")
+	sb.WriteString(loonGeneral)
+	sb.WriteString(fmt.Sprintf("proxy-test-url = %s\n\n", client.testURL))
+
+	sb.WriteString("[Proxy]\n")
+	for _, line := range client.lines {
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("[Proxy Group]\n")
+	for _, group := range client.groups {
+		line := surgeGroup(group, ",")
+		if group.Type == "load-balance" {
+			algorithm := "Round-Robin"
+			if group.Strategy == "consistent-hashing" {
+				algorithm = "PCC"
+			}
+			line += ",algorithm=" + algorithm
+		}
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("[Rule]\n")
+	for _, rule := range client.rules {
+		sb.WriteString(surgeRule(rule) + "\n")
+	}
+
+	return sb.String(), nil
+}
+
+// loonProxy 将Clash.Meta代理配置转换为Loon的代理行，Loon无法表示的节点返回false
+func loonProxy(proxy map[string]interface{}) (string, bool) {
+	name := stringField(proxy, "name")
+	server := stringField(proxy, "server")
+	port := strconv.Itoa(intField(proxy, "port"))
+	var options []string
+
+	switch stringField(proxy, "type") {
+	case "ss":
+		options = []string{"Shadowsocks", server, port, stringField(proxy, "cipher"), strconv.Quote(stringField(proxy, "password"))}
+		switch stringField(proxy, "plugin") {
+		case "":
+		case "obfs":
+			opts := mapField(proxy, "plugin-opts")
+			options = append(options,
+				keyValue("obfs-name", stringOr(stringField(opts, "mode"), "http")),
+				keyValue("obfs-host", stringField(opts, "host")))
+		default:
+			return "", false
+		}
+		options = append(options, keyTrue("udp", boolField(proxy, "udp")))
+	case "ssr":
+		options = []string{"ShadowsocksR", server, port, stringField(proxy, "cipher"), strconv.Quote(stringField(proxy, "password")),
+			keyValue("protocol", stringField(proxy, "protocol")),
+			keyValue("protocol-param", stringField(proxy, "protocol-param")),
+			keyValue("obfs", stringField(proxy, "obfs")),
+			keyValue("obfs-param", stringField(proxy, "obfs-param")),
+		}
+	case "vmess":
+		options = []string{"vmess", server, port, stringOr(stringField(proxy, "cipher"), "auto"), strconv.Quote(stringField(proxy, "uuid"))}
+		if !setLoonTransport(&options, proxy) {
+			return "", false
+		}
+		options = append(options, keyValue("alterId", strconv.Itoa(intField(proxy, "alterId"))))
+		if boolField(proxy, "tls") {
+			options = append(options, "over-tls=true")
+			options = append(options, clientTLS(proxy, "servername")...)
+		}
+	case "vless":
+		options = []string{"VLESS", server, port, strconv.Quote(stringField(proxy, "uuid"))}
+		if !setLoonTransport(&options, proxy) {
+			return "", false
+		}
+		if flow := stringField(proxy, "flow"); flow != "" {
+			if flow != "xtls-rprx-vision" {
+				return "", false
+			}
+			options = append(options, keyValue("flow", flow))
+		}
+		reality := mapField(proxy, "reality-opts")
+		if boolField(proxy, "tls") || reality != nil {
+			options = append(options, "over-tls=true")
+			options = append(options, clientTLS(proxy, "servername")...)
+		}
+		if reality != nil {
+			options = append(options,
+				keyValue("public-key", stringField(reality, "public-key")),
+				keyValue("short-id", stringField(reality, "short-id")))
+		}
+	case "trojan":
+		if mapField(proxy, "reality-opts") != nil {
+			return "", false
+		}
+		options = []string{"trojan", server, port, strconv.Quote(stringField(proxy, "password"))}
+		if !setLoonTransport(&options, proxy) {
+			return "", false
+		}
+		options = append(options, clientTLS(proxy, "sni")...)
+	case "hysteria2":
+		options = []string{"Hysteria2", server, port, strconv.Quote(stringField(proxy, "password"))}
+		switch obfs := stringField(proxy, "obfs"); obfs {
+		case "":
+		case "salamander":
+			options = append(options, keyValue("salamander-password", stringField(proxy, "obfs-password")))
+		default:
+			return "", false
+		}
+		if down := parseMbps(proxy["down"]); down > 0 {
+			options = append(options, keyValue("download-bandwidth", strconv.Itoa(down)))
+		}
+		options = append(options, clientTLS(proxy, "sni")...)
+		options = append(options, keyTrue("udp", boolField(proxy, "udp")))
+	case "http":
+		scheme := "http"
+		if boolField(proxy, "tls") {
+			scheme = "https"
+		}
+		options = append([]string{scheme, server, port}, loonAuth(proxy)...)
+		if scheme == "https" {
+			options = append(options, clientTLS(proxy, "sni")...)
+		}
+	case "socks5":
+		options = append([]string{"socks5", server, port}, loonAuth(proxy)...)
+		if boolField(proxy, "tls") {
+			options = append(options, "over-tls=true")
+			options = append(options, clientTLS(proxy, "sni")...)
+		}
+		options = append(options, keyTrue("udp", boolField(proxy, "udp")))
+	case "wireguard":
+		if stringField(proxy, "ip") == "" {
+			return "", false
+		}
+		peer := []string{
+			keyValue("public-key", strconv.Quote(stringField(proxy, "public-key"))),
+			`allowed-ips="0.0.0.0/0,::/0"`,
+			fmt.Sprintf("endpoint=%s:%d", server, intField(proxy, "port")),
+		}
+		if psk := stringField(proxy, "pre-shared-key"); psk != "" {
+			peer = append(peer, keyValue("preshared-key", strconv.Quote(psk)))
+		}
+		if reserved := intListField(proxy, "reserved"); len(reserved) > 0 {
+			values := make([]string, len(reserved))
+			for i, value := range reserved {
+				values[i] = strconv.Itoa(value)
+			}
+			peer = append(peer, "reserved=["+strings.Join(values, ",")+"]")
+		}
+		options = []string{"WireGuard",
+			keyValue("interface-ip", strings.TrimSuffix(stringField(proxy, "ip"), "/32")),
+			keyValue("interface-ipv6", strings.TrimSuffix(stringField(proxy, "ipv6"), "/128")),
+			keyValue("private-key", strconv.Quote(stringField(proxy, "private-key"))),
+		}
+		if mtu := intField(proxy, "mtu"); mtu > 0 {
+			options = append(options, keyValue("mtu", strconv.Itoa(mtu)))
+		}
+		options = append(options, "peers=[{"+strings.Join(peer, ",")+"}]")
+	default:
+		// hysteria、tuic、snell等Loon不支持的类型
+		return "", false
+	}
+
+	return name + " = " + joinOptions(",", options...), true
+}
+
+// setLoonTransport 设置传输方式，Loon支持tcp、ws与HTTP伪装
+func setLoonTransport(options *[]string, proxy map[string]interface{}) bool {
+	switch stringField(proxy, "network") {
+	case "", "tcp":
+		*options = append(*options, "transport=tcp")
+	case "ws":
+		opts := mapField(proxy, "ws-opts")
+		*options = append(*options, "transport=ws",
+			keyValue("path", stringField(opts, "path")),
+			keyValue("host", stringField(mapField(opts, "headers"), "Host")))
+	case "http":
+		opts := mapField(proxy, "http-opts")
+		*options = append(*options, "transport=http")
+		if paths := listField(opts, "path"); len(paths) > 0 {
+			*options = append(*options, keyValue("path", paths[0]))
+		}
+		if hosts := listField(mapField(opts, "headers"), "Host"); len(hosts) > 0 {
+			*options = append(*options, keyValue("host", hosts[0]))
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// loonAuth 生成HTTP与SOCKS5代理的用户名与密码
+func loonAuth(proxy map[string]interface{}) []string {
+	username := stringField(proxy, "username")
+	if username == "" {
+		return nil
+	}
+	return []string{username, strconv.Quote(stringField(proxy, "password"))}
+}
@@ -1,16 +1,13 @@
 package service
 
 import (
-	"context"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/filter"
 	"github.com/nariahlamb/sharesubweb/model"
 )
 
@@ -22,6 +19,9 @@ type NodeService struct {
 	stopCh        chan struct{}
 	wg            sync.WaitGroup
 	proxyTester   *ProxyTester
+	apiChecker    *APICheckService
+	geoService    *GeoService
+	ipQuality     *IPQualityService
 }
 
 // NewNodeService 创建节点服务
@@ -30,7 +30,9 @@ func NewNodeService(cfg *config.Config) *NodeService {
 		cfg:           cfg,
 		checkInterval: time.Duration(cfg.NodeCheck.Interval) * time.Minute,
 		stopCh:        make(chan struct{}),
-		proxyTester:   NewProxyTester(cfg.NodeCheck.Timeout),
+		proxyTester:   NewProxyTester(cfg.NodeCheck.Timeout, cfg.NodeCheck.LocalPort),
+		geoService:    NewGeoService(cfg),
+		ipQuality:     NewIPQualityService(cfg),
 	}
 }
 
@@ -39,6 +41,11 @@ func (s *NodeService) SetSubscriptionService(subService *SubscriptionService) {
 	s.subService = subService
 }
 
+// SetAPICheckService 设置API检测服务
+func (s *NodeService) SetAPICheckService(apiChecker *APICheckService) {
+	s.apiChecker = apiChecker
+}
+
 // Start 启动节点服务
 func (s *NodeService) Start() {
 	if s.subService == nil {
@@ -130,7 +137,7 @@ func (s *NodeService) CheckNode(node *model.ProxyNode) {
 	}
 
 	// 测试API连通性
-	if s.cfg.NodeCheck.API.Enable {
+	if s.cfg.NodeCheck.API.Enable && s.apiChecker != nil {
 		s.checkAPIConnectivity(node)
 	}
 
@@ -147,207 +154,177 @@ func (s *NodeService) checkNodeConnectivity(node *model.ProxyNode) (bool, int) {
 
 // 检测API连通性
 func (s *NodeService) checkAPIConnectivity(node *model.ProxyNode) {
-	// 初始化API连通性映射
-	if node.APIConnectivity == nil {
-		node.APIConnectivity = make(map[string]bool)
-	}
-	
-	// 获取API测试的超时配置，如果未配置则使用默认值
-	apiTimeout := s.cfg.NodeCheck.API.Timeout
-	if apiTimeout <= 0 {
-		apiTimeout = s.cfg.NodeCheck.Timeout // 使用通用超时
-	}
-	
-	// 获取重试次数
-	retryCount := s.cfg.NodeCheck.API.RetryCount
-	if retryCount <= 0 {
-		retryCount = 1 // 默认重试1次
-	}
-	
-	// 为每个目标API进行测试
-	for _, target := range s.cfg.NodeCheck.API.List {
-		// 默认为不可连接
-		node.APIConnectivity[target.Name] = false
-		
-		// 准备请求URL，为特定API添加特殊路径
-		requestURL := target.URL
-		switch target.Name {
-		case "OpenAI":
-			requestURL = fmt.Sprintf("%s/v1/models", target.URL) // 使用OpenAI的models接口进行测试
-		case "Gemini":
-			requestURL = fmt.Sprintf("%s/v1beta/models", target.URL) // 使用Gemini的models接口进行测试
-		}
-		
-		// 准备请求头
-		headers := make(map[string]string)
-		headers["User-Agent"] = "ShareSubWeb/1.0"
-		headers["Content-Type"] = "application/json"
-		
-		// 添加配置中的自定义请求头
-		for key, value := range target.Headers {
-			headers[key] = value
-		}
-		
-		// 使用代理测试器测试API连通性
-		success := s.proxyTester.TestAPIConnectivity(node, requestURL, headers, retryCount)
-		
-		// 更新节点的API连通性
-		node.APIConnectivity[target.Name] = success
-	}
+	s.apiChecker.CheckProxyNode(node)
 }
 
 // 检测IP质量
 func (s *NodeService) checkIPQuality(node *model.ProxyNode) {
-	// 获取IP信息的服务，如ipinfo.io
-	// 初始化IP信息
-	if node.IPInfo == nil {
-		node.IPInfo = &model.IPInfo{
-			Country: "Unknown",
-			CountryCode: "UN",
-		}
-	}
+	// 清除上次检测的出口信息，出口检测或地理位置查询失败时使用根据节点名称推断的地区
+	node.OutletIP = ""
+	node.IPInfo = inferIPInfo(node.Name)
 	
 	// 如果服务器地址为空，跳过
 	if node.Server == "" {
 		return
 	}
 	
-	// 尝试通过节点代理获取IP信息
-	client, err := s.proxyTester.CreateProxyHTTPClient(node)
-	if err != nil {
-		return
-	}
-	
-	// 访问IP信息API
-	ipInfoURL := "https://ipinfo.io/json"
-	req, err := http.NewRequest("GET", ipInfoURL, nil)
-	if err != nil {
-		return
-	}
-	
 	timeout := s.cfg.NodeCheck.IPQuality.Timeout
 	if timeout <= 0 {
 		timeout = 10 // 默认10秒
 	}
 	
-	// 设置超时上下文
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
-	req = req.WithContext(ctx)
-	defer cancel()
+	// 查询入口IP信息，检测结束后根据入口与出口判断线路类型
+	s.checkEntryIP(node, time.Duration(timeout)*time.Second)
+	defer func() {
+		node.Route = classifyRoute(node)
+	}()
 	
-	// 发送请求
-	resp, err := client.Do(req)
+	// 尝试通过节点代理获取出口IP
+	client, err := s.proxyTester.CreateProxyHTTPClient(node)
 	if err != nil {
 		return
 	}
-	defer resp.Body.Close()
+	client.Timeout = time.Duration(timeout) * time.Second
 	
-	// 解析响应
-	if resp.StatusCode != http.StatusOK {
-		return
-	}
-	
-	// 读取响应
-	body, err := ioutil.ReadAll(resp.Body)
+	outletIP, err := DetectOutletIP(client)
 	if err != nil {
 		return
 	}
+	node.OutletIP = outletIP
 	
-	// 解析JSON
-	var ipInfo map[string]interface{}
-	if err := json.Unmarshal(body, &ipInfo); err != nil {
+	// 按配置的提供者顺序查询出口IP信息
+	info, err := s.geoService.Lookup(outletIP)
+	if err != nil {
+		fmt.Printf("查询IP %s 信息失败: %v\n", outletIP, err)
 		return
 	}
 	
-	// 更新IP信息
-	if country, ok := ipInfo["country"].(string); ok {
-		node.IPInfo.CountryCode = country
-	}
-	
-	if region, ok := ipInfo["region"].(string); ok {
-		node.IPInfo.Region = region
-	}
-	
-	if city, ok := ipInfo["city"].(string); ok {
-		node.IPInfo.City = city
-	}
-	
-	if org, ok := ipInfo["org"].(string); ok {
-		// 通常org格式为 "AS13335 Cloudflare, Inc."
-		parts := strings.SplitN(org, " ", 2)
-		if len(parts) > 0 {
-			node.IPInfo.ASN = parts[0]
-		}
-		if len(parts) > 1 {
-			node.IPInfo.ISP = parts[1]
-		} else {
-			node.IPInfo.ISP = org
+	// 评估出口IP质量
+	info.Quality = s.ipQuality.Evaluate(info)
+	node.IPInfo = info
+}
+
+// inferIPInfo 根据节点名称推断IP信息，无法识别时为Unknown，国家代码为空以免生成未知地区的代理组
+func inferIPInfo(name string) *model.IPInfo {
+	code := model.InferCountry(name)
+	if code == "" {
+		return &model.IPInfo{
+			Country: "Unknown",
 		}
 	}
 	
-	// 获取国家名称
-	if node.IPInfo.CountryCode != "" {
-		node.IPInfo.Country = getCountryName(node.IPInfo.CountryCode)
+	return &model.IPInfo{
+		Country:     model.CountryName(code, model.LanguageZh),
+		CountryCode: code,
+		Source:      "name",
+		Inferred:    true,
 	}
 }
 
-// getCountryName 根据国家代码获取国家名称
-func getCountryName(code string) string {
-	countryCodes := map[string]string{
-		"CN": "中国",
-		"HK": "香港",
-		"TW": "台湾",
-		"JP": "日本",
-		"KR": "韩国",
-		"SG": "新加坡",
-		"US": "美国",
-		"CA": "加拿大",
-		"GB": "英国",
-		"DE": "德国",
-		"FR": "法国",
-		"AU": "澳大利亚",
-		// 其他常见国家...
+// 检测入口IP
+func (s *NodeService) checkEntryIP(node *model.ProxyNode, timeout time.Duration) {
+	node.EntryIP = ""
+	node.EntryIPInfo = nil
+	
+	entryIP, err := ResolveEntryIP(node.Server, timeout)
+	if err != nil {
+		fmt.Printf("解析节点 %s 入口地址失败: %v\n", node.Server, err)
+		return
 	}
+	node.EntryIP = entryIP
 	
-	if name, ok := countryCodes[code]; ok {
-		return name
+	info, err := s.geoService.Lookup(entryIP)
+	if err != nil {
+		fmt.Printf("查询入口IP %s 信息失败: %v\n", entryIP, err)
+		return
 	}
-	return code
+	node.EntryIPInfo = info
 }
 
-// RenameNodes 重命名节点
-func (s *NodeService) RenameNodes() {
-	if !s.cfg.NodeProcess.Rename.Enable {
-		return
+// RenamePreview 重命名预览结果
+type RenamePreview struct {
+	ID     string `json:"id"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// PreviewRename 预览重命名结果，在节点副本上处理，不修改源节点；renameCfg为空时使用当前配置
+func (s *NodeService) PreviewRename(renameCfg *config.RenameConfig, expression string) ([]RenamePreview, error) {
+	if renameCfg == nil {
+		renameCfg = &s.cfg.NodeProcess.Rename
+	}
+	if _, err := NewRenamer(*renameCfg); err != nil {
+		return nil, err
+	}
+	if err := config.ValidateDedupeFormat(renameCfg.DedupeFormat); err != nil {
+		return nil, err
 	}
 	
-	template := s.cfg.NodeProcess.Rename.Template
-	if template == "" {
-		return
+	nodes, err := s.FilterNodesBy(expression)
+	if err != nil {
+		return nil, err
 	}
 	
-	// 获取所有节点
-	nodes := s.subService.GetAllNodes()
-	for _, node := range nodes {
-		// 重命名节点
-		newName := node.RenameNode(template)
-		node.Name = newName
+	// 预览所有过滤后的节点，不按全局配置排序与限制数量
+	processed := NewNodeProcessor(*renameCfg, config.SelectConfig{}).Process(nodes)
+	previews := make([]RenamePreview, len(processed))
+	for i, node := range processed {
+		previews[i] = RenamePreview{ID: node.ID, Before: node.OriginalName, After: node.Name}
+	}
+	return previews, nil
+}
+
+// FilterNodesBy 按配置过滤节点后，再使用额外的表达式过滤
+func (s *NodeService) FilterNodesBy(expression string) ([]*model.ProxyNode, error) {
+	f, err := filter.Compile(expression)
+	if err != nil {
+		return nil, err
 	}
+	return f.Apply(s.FilterNodes()), nil
 }
 
 // FilterNodes 过滤节点
 func (s *NodeService) FilterNodes() []*model.ProxyNode {
-	if !s.cfg.NodeProcess.Filter.Enable {
-		return s.subService.GetAllNodes()
+	return filterNodes(s.subService.GetAllNodes(), s.cfg.NodeProcess.Filter)
+}
+
+// ProfileNodes 获取输出配置对应的节点：先按订阅或分组选择，再使用配置的过滤条件
+func (s *NodeService) ProfileNodes(profile config.ProfileConfig) []*model.ProxyNode {
+	allNodes := s.subService.GetAllNodes()
+	if len(profile.Groups) > 0 {
+		selected := make([]*model.ProxyNode, 0, len(allNodes))
+		for _, node := range allNodes {
+			if containsString(profile.Groups, node.SubscriptionName) ||
+				containsString(profile.Groups, node.SubscriptionID) ||
+				(node.GroupID != "" && containsString(profile.Groups, node.GroupID)) {
+				selected = append(selected, node)
+			}
+		}
+		allNodes = selected
+	}
+	return filterNodes(allNodes, profile.Filter)
+}
+
+// filterNodes 按过滤配置过滤节点
+func filterNodes(allNodes []*model.ProxyNode, cfg config.FilterConfig) []*model.ProxyNode {
+	if !cfg.Enable {
+		return allNodes
 	}
 	
-	includeKeywords := s.cfg.NodeProcess.Filter.IncludeKeywords
-	excludeKeywords := s.cfg.NodeProcess.Filter.ExcludeKeywords
+	// 配置中的表达式已在加载时校验
+	expression, err := filter.Compile(cfg.Expression)
+	if err != nil {
+		fmt.Printf("过滤表达式无效: %v\n", err)
+		expression = nil
+	}
 	
-	// 获取所有节点
-	allNodes := s.subService.GetAllNodes()
-	if len(includeKeywords) == 0 && len(excludeKeywords) == 0 {
-		return allNodes
+	includeKeywords := cfg.IncludeKeywords
+	excludeKeywords := cfg.ExcludeKeywords
+	minIPScore := cfg.MinIPScore
+	ipTypes := cfg.IPTypes
+	
+	if len(includeKeywords) == 0 && len(excludeKeywords) == 0 && minIPScore <= 0 && len(ipTypes) == 0 {
+		return expression.Apply(allNodes)
 	}
 	
 	var filteredNodes []*model.ProxyNode
@@ -378,12 +355,37 @@ func (s *NodeService) FilterNodes() []*model.ProxyNode {
 			}
 		}
 		
+		// 检查IP质量，未完成IP质量检测的节点不做限制
+		if node.IPInfo != nil && node.IPInfo.Quality != nil {
+			quality := node.IPInfo.Quality
+			if minIPScore > 0 && quality.Score < minIPScore {
+				continue
+			}
+			if len(ipTypes) > 0 && !containsString(ipTypes, quality.Type) {
+				continue
+			}
+		}
+		
+		if !expression.Match(node) {
+			continue
+		}
+		
 		filteredNodes = append(filteredNodes, node)
 	}
 	
 	return filteredNodes
 }
 
+// containsString 判断字符串切片中是否包含指定值（不区分大小写）
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
 // Contains 判断字符串是否包含子串（不区分大小写）
 func Contains(s, substr string) bool {
 	s, substr = strings.ToLower(s), strings.ToLower(substr)
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// TestProfileNodes 按订阅名称、订阅ID或分组ID选择节点，再使用输出配置的过滤条件
+func TestProfileNodes(t *testing.T) {
+	subService := NewSubscriptionService(&config.Config{})
+	node := func(id, country, groupID string) *model.ProxyNode {
+		return &model.ProxyNode{ID: id, Name: id, GroupID: groupID, IPInfo: &model.IPInfo{CountryCode: country}}
+	}
+	for _, sub := range []*model.Subscription{
+		{ID: "sub-a", Name: "airport-a", Nodes: []*model.ProxyNode{node("a1", "JP", ""), node("a2", "US", "premium")}},
+		{ID: "sub-b", Name: "airport-b", Nodes: []*model.ProxyNode{node("b1", "JP", "premium"), node("b2", "HK", "")}},
+		{ID: "sub-c", Name: "airport-c", Nodes: []*model.ProxyNode{node("c1", "JP", "")}},
+	} {
+		for _, n := range sub.Nodes {
+			n.SubscriptionID = sub.ID
+			n.SubscriptionName = sub.Name
+		}
+		subService.subscriptions[sub.ID] = sub
+	}
+	s := NewNodeService(&config.Config{})
+	s.SetSubscriptionService(subService)
+
+	tests := []struct {
+		name    string
+		profile config.ProfileConfig
+		want    []string
+	}{
+		{"未配置分组时包含全部节点", config.ProfileConfig{}, []string{"a1", "a2", "b1", "b2", "c1"}},
+		{"订阅名称", config.ProfileConfig{Groups: []string{"airport-b"}}, []string{"b1", "b2"}},
+		{"订阅ID", config.ProfileConfig{Groups: []string{"sub-c"}}, []string{"c1"}},
+		{"分组ID", config.ProfileConfig{Groups: []string{"premium"}}, []string{"a2", "b1"}},
+		{"多个条件取并集", config.ProfileConfig{Groups: []string{"sub-a", "airport-c", "premium"}}, []string{"a1", "a2", "b1", "c1"}},
+		{"没有匹配的分组", config.ProfileConfig{Groups: []string{"unknown"}}, []string{}},
+		{
+			"分组后再过滤",
+			config.ProfileConfig{Groups: []string{"premium", "airport-c"}, Filter: config.FilterConfig{Enable: true, Expression: `country == "JP"`}},
+			[]string{"b1", "c1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := nodeIDs(s.ProfileNodes(test.profile))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("节点为 %v，期望 %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// NodeProcessor 输出阶段的节点处理：排序、数量限制与重命名，在节点副本上计算显示名称，不修改源节点
+type NodeProcessor struct {
+	rename    config.RenameConfig
+	selection config.SelectConfig
+}
+
+// NewNodeProcessor 创建节点处理器，每个输出配置可以使用不同的规则
+func NewNodeProcessor(rename config.RenameConfig, selection config.SelectConfig) *NodeProcessor {
+	return &NodeProcessor{rename: rename, selection: selection}
+}
+
+// Process 返回排序与筛选后的节点副本，副本的Name为处理后的显示名称，OriginalName为源名称
+func (p *NodeProcessor) Process(nodes []*model.ProxyNode) []*model.ProxyNode {
+	nodes = SelectNodes(nodes, p.selection)
+
+	names := make([]string, len(nodes))
+	for i, node := range nodes {
+		names[i] = node.Name
+	}
+
+	if p.rename.Enable {
+		if renamer, err := NewRenamer(p.rename); err != nil {
+			fmt.Printf("重命名配置无效: %v\n", err)
+		} else {
+			for i, node := range nodes {
+				names[i] = renamer.Rename(node)
+			}
+		}
+	}
+	if p.rename.Dedupe {
+		names = DedupeNames(names, p.rename.DedupeFormat)
+	}
+
+	processed := make([]*model.ProxyNode, len(nodes))
+	for i, node := range nodes {
+		clone := node.Clone()
+		clone.OriginalName = node.Name
+		clone.Name = names[i]
+		processed[i] = clone
+	}
+	return processed
+}
+
+// activeNodes 返回可用节点
+func activeNodes(nodes []*model.ProxyNode) []*model.ProxyNode {
+	active := make([]*model.ProxyNode, 0, len(nodes))
+	for _, node := range nodes {
+		if node.Active {
+			active = append(active, node)
+		}
+	}
+	return active
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/nariahlamb/sharesubweb/model"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// GenerateClashProvider 生成Clash代理集，只包含proxies列表，供Clash配置通过proxy-providers引用
+func (g *OutputGenerator) GenerateClashProvider(nodes []*model.ProxyNode) (string, error) {
+	proxies := make([]map[string]interface{}, 0, len(nodes))
+	for _, node := range g.processor.Process(activeNodes(nodes)) {
+		proxy := clashProxy(node)
+		if clashSupported(proxy) {
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(map[string]interface{}{"proxies": proxies}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// GenerateSingBoxProvider 生成sing-box出站集，只包含节点出站，结构按output.singbox.version的目标版本生成
+func (g *OutputGenerator) GenerateSingBoxProvider(nodes []*model.ProxyNode) (string, error) {
+	target := newSingBoxTarget(g.cfg.Output.SingBox.Version)
+	outbounds, endpoints, _ := g.singBoxOutbounds(nodes, target)
+	if outbounds == nil {
+		outbounds = []map[string]interface{}{}
+	}
+
+	provider := map[string]interface{}{"outbounds": outbounds}
+	if len(endpoints) > 0 {
+		provider["endpoints"] = endpoints
+	}
+	data, err := json.MarshalIndent(provider, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
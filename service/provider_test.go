@@ -0,0 +1,162 @@
+package service
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// providerTestNodes 使用vmess测试数据，部分节点设置出口国家以生成地区代理组
+func providerTestNodes(t *testing.T) []*model.ProxyNode {
+	t.Helper()
+	_, nodes := parseClashTestdata(t, filepath.Join("testdata", "clash", "vmess.yaml"))
+	for i, node := range nodes {
+		code := "US"
+		if i%2 == 0 {
+			code = "JP"
+		}
+		node.IPInfo = &model.IPInfo{CountryCode: code}
+	}
+	return nodes
+}
+
+// TestClashProxyProvider 启用代理集时配置不内联节点，代理组引用代理集，地区代理组的filter只匹配组内节点
+func TestClashProxyProvider(t *testing.T) {
+	nodes := providerTestNodes(t)
+	cfg := &config.Config{}
+	cfg.Output.Clash.RegionGroups.Enable = true
+	cfg.Output.Clash.ProxyProvider = config.ProxyProviderConfig{
+		Enable: true,
+		URL:    "https://example.com/provider/{profile}?token=secret",
+	}
+	profile := config.ProfileConfig{Name: "mobile"}
+	generator := NewProfileOutputGenerator(cfg, profile)
+
+	output, err := generator.GenerateClashConfig(nodes)
+	if err != nil {
+		t.Fatalf("生成Clash配置失败: %v", err)
+	}
+	checkGolden(t, filepath.Join("testdata", "provider", "clash.golden"), []byte(output))
+
+	var clashConfig struct {
+		Proxies        []map[string]interface{}          `yaml:"proxies"`
+		ProxyProviders map[string]map[string]interface{} `yaml:"proxy-providers"`
+		ProxyGroups    []ClashProxyGroup                 `yaml:"proxy-groups"`
+	}
+	if err := yaml.Unmarshal([]byte(output), &clashConfig); err != nil {
+		t.Fatal(err)
+	}
+	if len(clashConfig.Proxies) != 0 {
+		t.Errorf("启用代理集时不应内联节点，得到 %d 个", len(clashConfig.Proxies))
+	}
+	if url := clashConfig.ProxyProviders["nodes"]["url"]; url != "https://example.com/provider/mobile?token=secret" {
+		t.Errorf("代理集地址为 %v", url)
+	}
+
+	// 代理集中的节点与各代理组的成员一致
+	provider, err := generator.GenerateClashProvider(nodes)
+	if err != nil {
+		t.Fatalf("生成代理集失败: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, proxy := range clashProxies(t, []byte(provider)) {
+		names[proxy["name"].(string)] = true
+	}
+	if len(names) != len(nodes) {
+		t.Fatalf("代理集包含 %d 个节点，期望 %d", len(names), len(nodes))
+	}
+
+	parts, err := buildClashParts(cfg.Output.Clash, "", generator.processor.Process(nodes), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, group := range parts.regionGroups {
+		var generated *ClashProxyGroup
+		for i := range clashConfig.ProxyGroups {
+			if clashConfig.ProxyGroups[i].Name == group.Name {
+				generated = &clashConfig.ProxyGroups[i]
+			}
+		}
+		if generated == nil || len(generated.Use) != 1 || generated.Use[0] != "nodes" {
+			t.Fatalf("地区代理组 %s 未引用代理集: %+v", group.Name, generated)
+		}
+		filter := regexp.MustCompile(generated.Filter)
+		matched := 0
+		for name := range names {
+			if filter.MatchString(name) {
+				matched++
+			}
+		}
+		if matched != len(group.Proxies) {
+			t.Errorf("地区代理组 %s 的filter匹配 %d 个节点，期望 %d", group.Name, matched, len(group.Proxies))
+		}
+	}
+}
+
+// TestClashProxyProviderToken 代理集地址使用各输出配置自己的名称与访问令牌
+func TestClashProxyProviderToken(t *testing.T) {
+	nodes := providerTestNodes(t)
+	cfg := &config.Config{}
+	cfg.Output.Clash.ProxyProvider = config.ProxyProviderConfig{
+		Enable: true,
+		URL:    "https://example.com/provider/{profile}?token={token}",
+	}
+	cfg.Profiles = []config.ProfileConfig{
+		{Name: "mobile", Token: "mobile-secret"},
+		{Name: "desktop", Token: "a&b=c"},
+	}
+
+	for _, profile := range cfg.Profiles {
+		output, err := NewProfileOutputGenerator(cfg, profile).GenerateClashConfig(nodes)
+		if err != nil {
+			t.Fatalf("生成 %s 的Clash配置失败: %v", profile.Name, err)
+		}
+		var clashConfig struct {
+			ProxyProviders map[string]map[string]interface{} `yaml:"proxy-providers"`
+		}
+		if err := yaml.Unmarshal([]byte(output), &clashConfig); err != nil {
+			t.Fatal(err)
+		}
+		want := "https://example.com/provider/" + profile.Name + "?token=" + url.QueryEscape(profile.Token)
+		if got := clashConfig.ProxyProviders["nodes"]["url"]; got != want {
+			t.Errorf("%s 的代理集地址为 %v，期望 %s", profile.Name, got, want)
+		}
+	}
+}
+
+// TestSingBoxProvider sing-box出站集只包含节点出站，跳过无法表示的节点
+func TestSingBoxProvider(t *testing.T) {
+	nodes := providerTestNodes(t)
+	output, err := NewOutputGenerator(&config.Config{}).GenerateSingBoxProvider(nodes)
+	if err != nil {
+		t.Fatalf("生成sing-box出站集失败: %v", err)
+	}
+
+	var provider struct {
+		Outbounds []map[string]interface{} `json:"outbounds"`
+	}
+	if err := json.Unmarshal([]byte(output), &provider); err != nil {
+		t.Fatal(err)
+	}
+	// sing-box无法表示的节点被跳过
+	supported := 0
+	for _, node := range nodes {
+		if _, ok := singBoxOutbound(clashProxy(node), node.Name); ok {
+			supported++
+		}
+	}
+	if len(provider.Outbounds) != supported {
+		t.Fatalf("出站数量为 %d，期望 %d", len(provider.Outbounds), supported)
+	}
+	for _, outbound := range provider.Outbounds {
+		if outbound["type"] != "vmess" {
+			t.Errorf("出站类型为 %v", outbound["type"])
+		}
+	}
+}
@@ -1,11 +1,11 @@
 package service
 
 import (
-	"context"
 	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/nariahlamb/sharesubweb/model"
@@ -14,13 +14,18 @@ import (
 
 // ProxyTester 代理测试器
 type ProxyTester struct {
-	Timeout time.Duration
+	Timeout   time.Duration
+	LocalPort int // 本地SOCKS5代理端口
 }
 
 // NewProxyTester 创建新的代理测试器
-func NewProxyTester(timeout int) *ProxyTester {
+func NewProxyTester(timeout int, localPort int) *ProxyTester {
+	if localPort <= 0 {
+		localPort = 1080
+	}
 	return &ProxyTester{
-		Timeout: time.Duration(timeout) * time.Second,
+		Timeout:   time.Duration(timeout) * time.Second,
+		LocalPort: localPort,
 	}
 }
 
@@ -29,7 +34,7 @@ func (pt *ProxyTester) TestNodeConnectivity(node *model.ProxyNode) (bool, int) {
 	start := time.Now()
 	
 	// 直接TCP连接测试
-	addr := fmt.Sprintf("%s:%d", node.Server, node.Port)
+	addr := net.JoinHostPort(node.Server, strconv.Itoa(node.Port))
 	conn, err := net.DialTimeout("tcp", addr, pt.Timeout)
 	if err != nil {
 		return false, 0
@@ -67,7 +72,7 @@ func (pt *ProxyTester) createShadowsocksDialer(node *model.ProxyNode, baseDialer
 	// 在实际实现中，应该使用适当的库来处理Shadowsocks协议
 	
 	// 简化实现，返回一个SOCKS5代理（假设已经有本地SOCKS代理）
-	return proxy.SOCKS5("tcp", "127.0.0.1:1080", nil, baseDialer)
+	return proxy.SOCKS5("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(pt.LocalPort)), nil, baseDialer)
 }
 
 // createV2RayDialer 创建V2Ray代理拨号器
@@ -76,7 +81,7 @@ func (pt *ProxyTester) createV2RayDialer(node *model.ProxyNode, baseDialer *net.
 	// 在实际实现中，应该使用适当的库来处理V2Ray协议
 	
 	// 简化实现，返回一个SOCKS5代理（假设已经有本地SOCKS代理）
-	return proxy.SOCKS5("tcp", "127.0.0.1:1080", nil, baseDialer)
+	return proxy.SOCKS5("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(pt.LocalPort)), nil, baseDialer)
 }
 
 // createTrojanDialer 创建Trojan代理拨号器
@@ -85,7 +90,7 @@ func (pt *ProxyTester) createTrojanDialer(node *model.ProxyNode, baseDialer *net
 	// 在实际实现中，应该使用适当的库来处理Trojan协议
 	
 	// 简化实现，返回一个SOCKS5代理（假设已经有本地SOCKS代理）
-	return proxy.SOCKS5("tcp", "127.0.0.1:1080", nil, baseDialer)
+	return proxy.SOCKS5("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(pt.LocalPort)), nil, baseDialer)
 }
 
 // CreateProxyHTTPClient 创建代理HTTP客户端
@@ -117,54 +122,10 @@ func (pt *ProxyTester) CreateProxyURL(node *model.ProxyNode) (*url.URL, error) {
 	// 并返回本地SOCKS5代理地址
 	
 	// 例如，假设本地已经有一个SOCKS5代理在运行
-	proxyURLStr := "socks5://127.0.0.1:1080"
+	proxyURLStr := fmt.Sprintf("socks5://127.0.0.1:%d", pt.LocalPort)
 	
 	// 实际情况下，应该根据节点类型启动相应的本地代理服务器
 	// 并返回对应的代理URL
 	
 	return url.Parse(proxyURLStr)
-}
-
-// TestAPIConnectivity 测试API连通性
-func (pt *ProxyTester) TestAPIConnectivity(node *model.ProxyNode, targetURL string, headers map[string]string, retryCount int) bool {
-	// 创建HTTP客户端
-	client, err := pt.CreateProxyHTTPClient(node)
-	if err != nil {
-		return false
-	}
-	
-	// 尝试请求，最多重试指定次数
-	for i := 0; i < retryCount; i++ {
-		req, err := http.NewRequest("GET", targetURL, nil)
-		if err != nil {
-			continue
-		}
-		
-		// 设置请求头
-		for key, value := range headers {
-			req.Header.Set(key, value)
-		}
-		
-		// 设置超时上下文
-		ctx, cancel := context.WithTimeout(context.Background(), pt.Timeout)
-		req = req.WithContext(ctx)
-		
-		// 发送请求
-		resp, err := client.Do(req)
-		cancel()
-		
-		if err != nil {
-			continue
-		}
-		
-		// 读取并关闭响应
-		resp.Body.Close()
-		
-		// 检查响应状态码
-		if resp.StatusCode >= 200 && resp.StatusCode < 400 {
-			return true
-		}
-	}
-	
-	return false
-} 
\ No newline at end of file
+}
\ No newline at end of file
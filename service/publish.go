@@ -0,0 +1,260 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	historyDirName    = "history"       // 输出目录下保存历史版本的目录
+	versionMetaName   = "meta.json"     // 历史版本的元数据文件
+	publishedMetaName = ".publish.json" // 当前发布版本的元数据文件
+	versionTimeFormat = "20060102-150405.000"
+)
+
+// ErrSafetyGate 安全检查未通过，本次输出未发布
+var ErrSafetyGate = errors.New("安全检查未通过")
+
+// publishMutex 串行化输出文件的发布与回滚
+var publishMutex sync.Mutex
+
+// OutputVersion 已发布的输出版本
+type OutputVersion struct {
+	Version string    `json:"version"` // 版本号，即发布时间
+	Time    time.Time `json:"time"`
+	Nodes   int       `json:"nodes"`   // 发布时的可用节点数
+	Files   []string  `json:"files"`   // 输出文件名
+	Current bool      `json:"current"` // 是否为当前发布的版本
+}
+
+// publish 发布输出文件：通过安全检查后原子写入输出目录，并保存为历史版本
+func (g *OutputGenerator) publish(nodeCount int, names []string, files map[string][]byte) error {
+	publishMutex.Lock()
+	defer publishMutex.Unlock()
+
+	if err := g.checkSafetyGate(nodeCount); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	version := &OutputVersion{
+		Version: now.Format(versionTimeFormat),
+		Time:    now,
+		Nodes:   nodeCount,
+		Files:   names,
+	}
+
+	for _, name := range names {
+		if err := writeFileAtomic(filepath.Join(g.outputDir, name), files[name]); err != nil {
+			return fmt.Errorf("保存%s失败: %v", name, err)
+		}
+	}
+	if err := g.saveVersion(version, files); err != nil {
+		return err
+	}
+	return writeJSONAtomic(filepath.Join(g.outputDir, publishedMetaName), version)
+}
+
+// checkSafetyGate 可用节点数少于下限，或比上次发布减少的比例超过上限时拒绝发布
+func (g *OutputGenerator) checkSafetyGate(nodeCount int) error {
+	gate := g.cfg.Output.SafetyGate
+	if !gate.Enable {
+		return nil
+	}
+
+	// 启用安全检查时至少需要一个节点，避免发布空配置
+	minNodes := gate.MinNodes
+	if minNodes < 1 {
+		minNodes = 1
+	}
+	if nodeCount < minNodes {
+		return fmt.Errorf("%w: 可用节点数 %d 少于 %d", ErrSafetyGate, nodeCount, minNodes)
+	}
+
+	if gate.MaxDropPercent <= 0 {
+		return nil
+	}
+	last, err := g.publishedVersion()
+	if err != nil || last.Nodes == 0 {
+		return nil
+	}
+	if drop := (last.Nodes - nodeCount) * 100 / last.Nodes; drop > gate.MaxDropPercent {
+		return fmt.Errorf("%w: 可用节点数从 %d 减少到 %d，减少 %d%% 超过 %d%%", ErrSafetyGate, last.Nodes, nodeCount, drop, gate.MaxDropPercent)
+	}
+	return nil
+}
+
+// saveVersion 保存历史版本，并删除超出output.versions的旧版本
+func (g *OutputGenerator) saveVersion(version *OutputVersion, files map[string][]byte) error {
+	keep := g.cfg.Output.Versions
+	if keep <= 0 {
+		return nil
+	}
+
+	dir := filepath.Join(g.outputDir, historyDirName, version.Version)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建历史版本目录失败: %v", err)
+	}
+	for _, name := range version.Files {
+		if err := writeFileAtomic(filepath.Join(dir, name), files[name]); err != nil {
+			return fmt.Errorf("保存历史版本失败: %v", err)
+		}
+	}
+	if err := writeJSONAtomic(filepath.Join(dir, versionMetaName), version); err != nil {
+		return fmt.Errorf("保存历史版本失败: %v", err)
+	}
+
+	names, err := g.versionNames()
+	if err != nil {
+		return err
+	}
+	for len(names) > keep {
+		if err := os.RemoveAll(filepath.Join(g.outputDir, historyDirName, names[0])); err != nil {
+			return fmt.Errorf("删除历史版本失败: %v", err)
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// Versions 获取历史版本，最新的版本在前
+func (g *OutputGenerator) Versions() ([]*OutputVersion, error) {
+	publishMutex.Lock()
+	defer publishMutex.Unlock()
+
+	names, err := g.versionNames()
+	if err != nil {
+		return nil, err
+	}
+	current := ""
+	if published, err := g.publishedVersion(); err == nil {
+		current = published.Version
+	}
+
+	versions := make([]*OutputVersion, 0, len(names))
+	for i := len(names) - 1; i >= 0; i-- {
+		version, err := readVersion(filepath.Join(g.outputDir, historyDirName, names[i], versionMetaName))
+		if err != nil {
+			continue
+		}
+		version.Current = version.Version == current
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// Rollback 将历史版本重新发布到输出目录，回滚不经过安全检查
+func (g *OutputGenerator) Rollback(name string) (*OutputVersion, error) {
+	publishMutex.Lock()
+	defer publishMutex.Unlock()
+
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("无效的版本: %s", name)
+	}
+	dir := filepath.Join(g.outputDir, historyDirName, name)
+	version, err := readVersion(filepath.Join(dir, versionMetaName))
+	if err != nil {
+		return nil, fmt.Errorf("版本不存在: %s", name)
+	}
+
+	// 先读取全部文件，避免只回滚了部分文件
+	files := make(map[string][]byte, len(version.Files))
+	for _, file := range version.Files {
+		data, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			return nil, fmt.Errorf("读取历史版本失败: %v", err)
+		}
+		files[file] = data
+	}
+	for _, file := range version.Files {
+		if err := writeFileAtomic(filepath.Join(g.outputDir, file), files[file]); err != nil {
+			return nil, fmt.Errorf("回滚%s失败: %v", file, err)
+		}
+	}
+	if err := writeJSONAtomic(filepath.Join(g.outputDir, publishedMetaName), version); err != nil {
+		return nil, err
+	}
+	version.Current = true
+	return version, nil
+}
+
+// publishedVersion 读取当前发布版本的元数据
+func (g *OutputGenerator) publishedVersion() (*OutputVersion, error) {
+	return readVersion(filepath.Join(g.outputDir, publishedMetaName))
+}
+
+// versionNames 历史版本名称，按发布时间升序排列
+func (g *OutputGenerator) versionNames() ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(g.outputDir, historyDirName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取历史版本失败: %v", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// readVersion 读取版本元数据
+func readVersion(path string) (*OutputVersion, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var version OutputVersion
+	if err := json.Unmarshal(data, &version); err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// writeJSONAtomic 以JSON格式原子写入文件
+func writeJSONAtomic(path string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic 先写入同目录下的临时文件再重命名，读取方不会读到写了一半的文件
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nariahlamb/sharesubweb/config"
+)
+
+// TestSaveOutputVersions 发布保留指定数量的历史版本，安全检查未通过时保留上次发布的文件，回滚恢复历史版本
+func TestSaveOutputVersions(t *testing.T) {
+	_, nodes := parseClashTestdata(t, filepath.Join("testdata", "clash", "ss.yaml"))
+	if len(nodes) < 3 {
+		t.Fatalf("测试数据节点数为 %d", len(nodes))
+	}
+
+	cfg := &config.Config{}
+	cfg.Output.LocalPath = t.TempDir()
+	cfg.Output.Versions = 2
+	cfg.Output.SafetyGate = config.SafetyGateConfig{Enable: true, MinNodes: 1, MaxDropPercent: 50}
+	generator := NewProfileOutputGenerator(cfg, config.ProfileConfig{Name: config.DefaultProfileName, Formats: []string{"clash", "v2ray"}})
+	output := filepath.Join(cfg.Output.LocalPath, "v2ray.txt")
+
+	// 发布三次，只保留最近两个版本
+	for i := 0; i < 3; i++ {
+		if err := generator.SaveOutput(nodes[:len(nodes)-i%2]); err != nil {
+			t.Fatalf("第%d次发布失败: %v", i+1, err)
+		}
+	}
+	versions, err := generator.Versions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("历史版本数为 %d，期望 2", len(versions))
+	}
+	if !versions[0].Current || versions[1].Current || versions[0].Nodes != len(nodes) || versions[1].Nodes != len(nodes)-1 {
+		t.Fatalf("历史版本错误: %+v %+v", versions[0], versions[1])
+	}
+	published, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 节点数减少超过一半或没有节点时不发布
+	for _, count := range []int{0, len(nodes)/2 - 1} {
+		err := generator.SaveOutput(nodes[:count])
+		if !errors.Is(err, ErrSafetyGate) {
+			t.Fatalf("%d个节点时应拒绝发布，得到 %v", count, err)
+		}
+	}
+	if data, _ := ioutil.ReadFile(output); string(data) != string(published) {
+		t.Fatal("安全检查未通过时输出文件被修改")
+	}
+
+	// 回滚到上一个版本
+	version, err := generator.Rollback(versions[1].Version)
+	if err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	if version.Nodes != len(nodes)-1 {
+		t.Errorf("回滚版本的节点数为 %d", version.Nodes)
+	}
+	history, err := ioutil.ReadFile(filepath.Join(cfg.Output.LocalPath, historyDirName, version.Version, "v2ray.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(output); string(data) != string(history) {
+		t.Error("回滚后的输出文件与历史版本不一致")
+	}
+	if versions, _ := generator.Versions(); !versions[1].Current {
+		t.Error("回滚后的版本应标记为当前版本")
+	}
+
+	for _, name := range []string{"", "../history", ".", "missing"} {
+		if _, err := generator.Rollback(name); err == nil {
+			t.Errorf("回滚到 %q 应失败", name)
+		}
+	}
+
+	// 输出目录中不残留临时文件
+	entries, err := ioutil.ReadDir(cfg.Output.LocalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == ".tmp" {
+			t.Errorf("残留临时文件: %s", entry.Name())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Output.LocalPath, publishedMetaName)); err != nil {
+		t.Error(err)
+	}
+}
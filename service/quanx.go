@@ -0,0 +1,271 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// quanxGroupTypes Clash代理组类型对应的Quantumult X策略类型
+var quanxGroupTypes = map[string]string{
+	"select":       "static",
+	"url-test":     "url-latency-benchmark",
+	"fallback":     "available",
+	"load-balance": "round-robin",
+}
+
+// quanxRuleTypes Clash规则类型对应的Quantumult X规则类型
+var quanxRuleTypes = map[string]string{
+	"DOMAIN":         "host",
+	"DOMAIN-SUFFIX":  "host-suffix",
+	"DOMAIN-KEYWORD": "host-keyword",
+	"IP-CIDR":        "ip-cidr",
+	"IP-CIDR6":       "ip6-cidr",
+	"GEOIP":          "geoip",
+	"MATCH":          "final",
+}
+
+// GenerateQuanXConfig 生成Quantumult X配置
+func (g *OutputGenerator) GenerateQuanXConfig(nodes []*model.ProxyNode) (string, error) {
+	client, err := g.buildClientConfig(nodes, quanxProxy)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# ShareSubWeb Subscription\n\n")
+	sb.WriteString("[general]\n")
+	sb.WriteString(fmt.Sprintf("server_check_url=%s\n\n", client.testURL))
+	sb.WriteString("[dns]\n")
+	sb.WriteString("server=119.29.29.29\n")
+	sb.WriteString("server=223.5.5.5\n\n")
+
+	sb.WriteString("[policy]\n")
+	for _, group := range client.groups {
+		members := make([]string, len(group.Proxies))
+		for i, member := range group.Proxies {
+			members[i] = quanxPolicy(member)
+		}
+		options := append([]string{group.Name}, members...)
+		if group.Type == "url-test" || group.Type == "fallback" {
+			options = append(options, keyValue("check-interval", strconv.Itoa(group.Interval)))
+		}
+		if group.Type == "url-test" && group.Tolerance > 0 {
+			options = append(options, keyValue("tolerance", strconv.Itoa(group.Tolerance)))
+		}
+		sb.WriteString(quanxGroupTypes[group.Type] + "=" + joinOptions(", ", options...) + "\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("[server_local]\n")
+	for _, line := range client.lines {
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("[filter_local]\n")
+	for _, rule := range client.rules {
+		fields := []string{quanxRuleTypes[rule.Type]}
+		if rule.Type != "MATCH" {
+			fields = append(fields, rule.Value)
+		}
+		fields = append(fields, quanxPolicy(rule.Policy))
+		sb.WriteString(strings.Join(fields, ", ") + "\n")
+	}
+
+	return sb.String(), nil
+}
+
+// quanxPolicy Quantumult X的内置策略为小写
+func quanxPolicy(policy string) string {
+	switch policy {
+	case "DIRECT":
+		return "direct"
+	case "REJECT":
+		return "reject"
+	}
+	return policy
+}
+
+// quanxProxy 将Clash.Meta代理配置转换为Quantumult X的节点行，Quantumult X无法表示的节点返回false
+func quanxProxy(proxy map[string]interface{}) (string, bool) {
+	address := net.JoinHostPort(stringField(proxy, "server"), strconv.Itoa(intField(proxy, "port")))
+	var options []string
+
+	switch stringField(proxy, "type") {
+	case "ss":
+		options = []string{"shadowsocks=" + address,
+			keyValue("method", stringField(proxy, "cipher")),
+			keyValue("password", stringField(proxy, "password")),
+		}
+		opts := mapField(proxy, "plugin-opts")
+		switch stringField(proxy, "plugin") {
+		case "":
+		case "obfs":
+			options = append(options,
+				keyValue("obfs", stringOr(stringField(opts, "mode"), "http")),
+				keyValue("obfs-host", stringField(opts, "host")))
+		case "v2ray-plugin":
+			if mode := stringField(opts, "mode"); mode != "" && mode != "websocket" {
+				return "", false
+			}
+			obfs := "ws"
+			if boolField(opts, "tls") {
+				obfs = "wss"
+			}
+			options = append(options,
+				keyValue("obfs", obfs),
+				keyValue("obfs-host", stringField(opts, "host")),
+				keyValue("obfs-uri", stringField(opts, "path")))
+		default:
+			return "", false
+		}
+		options = append(options, keyTrue("udp-relay", boolField(proxy, "udp")))
+	case "ssr":
+		options = []string{"shadowsocks=" + address,
+			keyValue("method", stringField(proxy, "cipher")),
+			keyValue("password", stringField(proxy, "password")),
+			keyValue("ssr-protocol", stringField(proxy, "protocol")),
+			keyValue("ssr-protocol-param", stringField(proxy, "protocol-param")),
+			keyValue("obfs", stringField(proxy, "obfs")),
+			keyValue("obfs-host", stringField(proxy, "obfs-param")),
+		}
+	case "vmess":
+		// Quantumult X的vmess不支持auto，使用chacha20-ietf-poly1305
+		method := stringField(proxy, "cipher")
+		switch method {
+		case "none", "aes-128-gcm", "chacha20-ietf-poly1305":
+		case "zero":
+			method = "none"
+		default:
+			method = "chacha20-ietf-poly1305"
+		}
+		options = []string{"vmess=" + address,
+			keyValue("method", method),
+			keyValue("password", stringField(proxy, "uuid")),
+		}
+		if !setQuanXTransport(&options, proxy, boolField(proxy, "tls")) {
+			return "", false
+		}
+		if intField(proxy, "alterId") > 0 {
+			options = append(options, "aead=false")
+		}
+		options = append(options, keyTrue("udp-relay", boolField(proxy, "udp")))
+	case "vless":
+		options = []string{"vless=" + address,
+			"method=none",
+			keyValue("password", stringField(proxy, "uuid")),
+		}
+		reality := mapField(proxy, "reality-opts")
+		if !setQuanXTransport(&options, proxy, boolField(proxy, "tls") || reality != nil) {
+			return "", false
+		}
+		if flow := stringField(proxy, "flow"); flow != "" {
+			if flow != "xtls-rprx-vision" {
+				return "", false
+			}
+			options = append(options, keyValue("vless-flow", flow))
+		}
+		if reality != nil {
+			options = append(options,
+				keyValue("reality-base64-pubkey", stringField(reality, "public-key")),
+				keyValue("reality-hex-shortid", stringField(reality, "short-id")))
+		}
+		options = append(options, keyTrue("udp-relay", boolField(proxy, "udp")))
+	case "trojan":
+		if mapField(proxy, "reality-opts") != nil {
+			return "", false
+		}
+		options = []string{"trojan=" + address, keyValue("password", stringField(proxy, "password"))}
+		if !setQuanXTransport(&options, proxy, true) {
+			return "", false
+		}
+		options = append(options, keyTrue("udp-relay", boolField(proxy, "udp")))
+	case "http":
+		options = []string{"http=" + address,
+			keyValue("username", stringField(proxy, "username")),
+			keyValue("password", stringField(proxy, "password")),
+		}
+		if boolField(proxy, "tls") {
+			options = append(options, "over-tls=true")
+			options = append(options, quanxTLS(proxy, "sni")...)
+		}
+	case "socks5":
+		options = []string{"socks5=" + address,
+			keyValue("username", stringField(proxy, "username")),
+			keyValue("password", stringField(proxy, "password")),
+		}
+		if boolField(proxy, "tls") {
+			options = append(options, "over-tls=true")
+			options = append(options, quanxTLS(proxy, "sni")...)
+		}
+		options = append(options, keyTrue("udp-relay", boolField(proxy, "udp")))
+	default:
+		// hysteria、tuic、wireguard、snell等Quantumult X不支持的类型
+		return "", false
+	}
+
+	options = append(options, keyValue("tag", stringField(proxy, "name")))
+	return joinOptions(", ", options...), true
+}
+
+// setQuanXTransport 设置传输方式与TLS，Quantumult X的ws与HTTP伪装通过obfs参数表示
+func setQuanXTransport(options *[]string, proxy map[string]interface{}, tls bool) bool {
+	sniKey := "servername"
+	if stringField(proxy, "type") == "trojan" {
+		sniKey = "sni"
+	}
+
+	switch stringField(proxy, "network") {
+	case "", "tcp":
+		if tls {
+			obfs := "over-tls"
+			if stringField(proxy, "type") == "trojan" {
+				*options = append(*options, "over-tls=true")
+				obfs = ""
+			}
+			*options = append(*options, keyValue("obfs", obfs))
+		}
+	case "ws":
+		opts := mapField(proxy, "ws-opts")
+		obfs := "ws"
+		if tls {
+			obfs = "wss"
+		}
+		*options = append(*options,
+			keyValue("obfs", obfs),
+			keyValue("obfs-host", stringField(mapField(opts, "headers"), "Host")),
+			keyValue("obfs-uri", stringField(opts, "path")))
+	case "http":
+		if tls {
+			return false
+		}
+		opts := mapField(proxy, "http-opts")
+		*options = append(*options, "obfs=http")
+		if hosts := listField(mapField(opts, "headers"), "Host"); len(hosts) > 0 {
+			*options = append(*options, keyValue("obfs-host", hosts[0]))
+		}
+		if paths := listField(opts, "path"); len(paths) > 0 {
+			*options = append(*options, keyValue("obfs-uri", paths[0]))
+		}
+	default:
+		return false
+	}
+
+	if tls {
+		*options = append(*options, quanxTLS(proxy, sniKey)...)
+	}
+	return true
+}
+
+// quanxTLS 生成TLS参数，sniKey为Clash中服务器名称的字段名
+func quanxTLS(proxy map[string]interface{}, sniKey string) []string {
+	options := []string{keyValue("tls-host", stringField(proxy, sniKey))}
+	if boolField(proxy, "skip-cert-verify") {
+		options = append(options, "tls-verification=false")
+	}
+	return options
+}
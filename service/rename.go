@@ -0,0 +1,167 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+	"golang.org/x/text/unicode/norm"
+)
+
+// defaultDedupeFormat 默认的重名编号格式
+const defaultDedupeFormat = "%s %02d"
+
+// renameStep 单个重命名步骤，输入当前名称，输出处理后的名称
+type renameStep func(name string, node *model.ProxyNode) string
+
+// Renamer 节点重命名流水线
+type Renamer struct {
+	steps []renameStep
+}
+
+// whitespacePattern 连续空白字符
+var whitespacePattern = regexp.MustCompile(`\s+`)
+
+// NewRenamer 根据配置创建重命名流水线
+func NewRenamer(cfg config.RenameConfig) (*Renamer, error) {
+	pipeline := cfg.Pipeline
+	if len(pipeline) == 0 {
+		pipeline = []config.RenameStep{{Type: "template"}, {Type: "prefix"}, {Type: "suffix"}}
+	}
+
+	renamer := &Renamer{}
+	for i, step := range pipeline {
+		fn, err := newRenameStep(step, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("重命名步骤 %d: %v", i+1, err)
+		}
+		renamer.steps = append(renamer.steps, fn)
+	}
+	return renamer, nil
+}
+
+// newRenameStep 创建重命名步骤
+func newRenameStep(step config.RenameStep, cfg config.RenameConfig) (renameStep, error) {
+	switch step.Type {
+	case "replace", "delete":
+		re, err := regexp.Compile(step.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("无效的正则表达式: %v", err)
+		}
+		replace := step.Replace
+		if step.Type == "delete" {
+			replace = ""
+		}
+		return func(name string, _ *model.ProxyNode) string {
+			return strings.TrimSpace(re.ReplaceAllString(name, replace))
+		}, nil
+	case "prefix":
+		value := stepValue(step.Value, cfg.AddPrefix)
+		return func(name string, _ *model.ProxyNode) string {
+			return value + name
+		}, nil
+	case "suffix":
+		value := stepValue(step.Value, cfg.AddSuffix)
+		return func(name string, _ *model.ProxyNode) string {
+			return name + value
+		}, nil
+	case "template":
+		template := stepValue(step.Value, cfg.Template)
+		return func(name string, node *model.ProxyNode) string {
+			// {名称}使用前面步骤处理后的名称
+			current := *node
+			current.Name = name
+			return current.RenameNode(template, cfg.Language)
+		}, nil
+	case "normalize":
+		form, err := normalizationForm(step.Form)
+		if err != nil {
+			return nil, err
+		}
+		return func(name string, _ *model.ProxyNode) string {
+			name = form.String(name)
+			return strings.TrimSpace(whitespacePattern.ReplaceAllString(name, " "))
+		}, nil
+	default:
+		return nil, fmt.Errorf("未知的步骤类型: %s", step.Type)
+	}
+}
+
+// stepValue 步骤未配置内容时使用默认值
+func stepValue(value, fallback string) string {
+	if value != "" {
+		return value
+	}
+	return fallback
+}
+
+// normalizationForm 解析Unicode规范化形式，默认NFKC（全角转半角、兼容字符转普通字符）
+func normalizationForm(name string) (norm.Form, error) {
+	switch strings.ToUpper(name) {
+	case "", "NFKC":
+		return norm.NFKC, nil
+	case "NFC":
+		return norm.NFC, nil
+	case "NFD":
+		return norm.NFD, nil
+	case "NFKD":
+		return norm.NFKD, nil
+	default:
+		return 0, fmt.Errorf("未知的规范化形式: %s", name)
+	}
+}
+
+// Rename 对单个节点执行重命名流水线，不修改节点本身
+func (r *Renamer) Rename(node *model.ProxyNode) string {
+	name := node.Name
+	for _, step := range r.steps {
+		name = step(name, node)
+	}
+	if name == "" {
+		return node.Name
+	}
+	return name
+}
+
+// DedupeNames 为重名的名称按出现顺序编号，如 "JP 01"、"JP 02"，唯一的名称保持不变
+// 格式无效时使用默认格式，避免不含编号的格式生成相同的名称而无法结束
+func DedupeNames(names []string, format string) []string {
+	if format == "" || config.ValidateDedupeFormat(format) != nil {
+		format = defaultDedupeFormat
+	}
+
+	counts := make(map[string]int, len(names))
+	for _, name := range names {
+		counts[name]++
+	}
+
+	used := make(map[string]bool, len(names))
+	for _, name := range names {
+		if counts[name] == 1 {
+			used[name] = true
+		}
+	}
+
+	result := make([]string, len(names))
+	next := make(map[string]int, len(names))
+	for i, name := range names {
+		if counts[name] == 1 {
+			result[i] = name
+			continue
+		}
+
+		// 编号后的名称与已有名称冲突时继续递增
+		for {
+			next[name]++
+			candidate := fmt.Sprintf(format, name, next[name])
+			if !used[candidate] {
+				used[candidate] = true
+				result[i] = candidate
+				break
+			}
+		}
+	}
+	return result
+}
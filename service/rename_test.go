@@ -0,0 +1,151 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// TestRenamer 各重命名步骤与默认流水线
+func TestRenamer(t *testing.T) {
+	node := &model.ProxyNode{
+		Name:    "[HK] IPLC 香港 01",
+		Latency: 80,
+		IPInfo:  &model.IPInfo{CountryCode: "JP"},
+	}
+
+	tests := []struct {
+		name string
+		cfg  config.RenameConfig
+		want string
+	}{
+		{
+			name: "replace",
+			cfg:  config.RenameConfig{Pipeline: []config.RenameStep{{Type: "replace", Pattern: `(?i)iplc\s*(\S+)`, Replace: "${1}专线"}}},
+			want: "[HK] 香港专线 01",
+		},
+		{
+			name: "delete",
+			cfg:  config.RenameConfig{Pipeline: []config.RenameStep{{Type: "delete", Pattern: `\[.*?\]`}}},
+			want: "IPLC 香港 01",
+		},
+		{
+			name: "prefix与suffix使用步骤内容",
+			cfg: config.RenameConfig{AddPrefix: "unused", Pipeline: []config.RenameStep{
+				{Type: "prefix", Value: "A-"}, {Type: "suffix", Value: "-Z"},
+			}},
+			want: "A-[HK] IPLC 香港 01-Z",
+		},
+		{
+			name: "prefix与suffix默认使用add-prefix与add-suffix",
+			cfg: config.RenameConfig{AddPrefix: "前 ", AddSuffix: " 后", Pipeline: []config.RenameStep{
+				{Type: "prefix"}, {Type: "suffix"},
+			}},
+			want: "前 [HK] IPLC 香港 01 后",
+		},
+		{
+			name: "template使用前面步骤处理后的名称",
+			cfg: config.RenameConfig{Pipeline: []config.RenameStep{
+				{Type: "delete", Pattern: `^\[\w+\]\s*`},
+				{Type: "template", Value: "{国家}|{名称}|{延迟}"},
+			}},
+			want: "JP|IPLC 香港 01|80ms",
+		},
+		{
+			name: "normalize",
+			cfg:  config.RenameConfig{Pipeline: []config.RenameStep{{Type: "normalize"}}},
+			want: "[HK] IPLC 香港 01",
+		},
+		{
+			name: "默认流水线依次执行template、prefix、suffix",
+			cfg:  config.RenameConfig{Template: "{国家} {名称}", AddPrefix: "<", AddSuffix: ">"},
+			want: "<JP [HK] IPLC 香港 01>",
+		},
+		{
+			name: "处理结果为空时保留原名称",
+			cfg:  config.RenameConfig{Pipeline: []config.RenameStep{{Type: "delete", Pattern: `.*`}}},
+			want: "[HK] IPLC 香港 01",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			renamer, err := NewRenamer(test.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := renamer.Rename(node); got != test.want {
+				t.Errorf("重命名结果为 %q，期望 %q", got, test.want)
+			}
+		})
+	}
+	if node.Name != "[HK] IPLC 香港 01" {
+		t.Errorf("重命名修改了源节点: %q", node.Name)
+	}
+}
+
+// TestRenamerNormalize 全角字符转半角，连续空白合并为一个空格
+func TestRenamerNormalize(t *testing.T) {
+	renamer, err := NewRenamer(config.RenameConfig{Pipeline: []config.RenameStep{{Type: "normalize", Form: "nfkc"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := renamer.Rename(&model.ProxyNode{Name: "　ＨＫ　　０１\t"}); got != "HK 01" {
+		t.Errorf("规范化结果为 %q", got)
+	}
+}
+
+// TestRenamerError 无效的步骤返回错误
+func TestRenamerError(t *testing.T) {
+	for _, step := range []config.RenameStep{
+		{Type: "unknown"},
+		{Type: "replace", Pattern: "("},
+		{Type: "delete", Pattern: "[a-"},
+		{Type: "normalize", Form: "NFX"},
+	} {
+		if _, err := NewRenamer(config.RenameConfig{Pipeline: []config.RenameStep{step}}); err == nil {
+			t.Errorf("步骤 %+v 应返回错误", step)
+		}
+	}
+}
+
+// TestDedupeNames 重名按出现顺序编号，编号后与已有名称冲突时继续递增
+func TestDedupeNames(t *testing.T) {
+	tests := []struct {
+		names  []string
+		format string
+		want   []string
+	}{
+		{[]string{"JP", "US", "SG"}, "", []string{"JP", "US", "SG"}},
+		{[]string{"JP", "US", "JP"}, "", []string{"JP 01", "US", "JP 02"}},
+		{[]string{"JP", "JP", "JP 02", "US"}, "", []string{"JP 01", "JP 03", "JP 02", "US"}},
+		{[]string{"JP", "JP"}, "%s-%d", []string{"JP-1", "JP-2"}},
+		{[]string{"JP", "JP"}, "%s #%x", []string{"JP #1", "JP #2"}},
+	}
+	for _, test := range tests {
+		if got := DedupeNames(test.names, test.format); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("DedupeNames(%q, %q) = %q，期望 %q", test.names, test.format, got, test.want)
+		}
+	}
+}
+
+// TestDedupeNamesInvalidFormat 不含编号的格式曾导致无限循环，现在使用默认格式
+func TestDedupeNamesInvalidFormat(t *testing.T) {
+	for _, format := range []string{"%s", "%s %s", "%d %s", "JP"} {
+		done := make(chan []string, 1)
+		go func() {
+			done <- DedupeNames([]string{"JP", "JP"}, format)
+		}()
+		select {
+		case got := <-done:
+			if want := []string{"JP 01", "JP 02"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("格式 %q 的结果为 %q，期望 %q", format, got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("格式 %q 导致DedupeNames无法结束", format)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// 常见CDN的ASN，入口位于这些网络时视为CDN中转
+var cdnASNs = map[string]bool{
+	"AS13335":  true, // Cloudflare
+	"AS209242": true, // Cloudflare Spectrum
+	"AS54113":  true, // Fastly
+	"AS20940":  true, // Akamai
+	"AS16625":  true, // Akamai
+	"AS60068":  true, // CDN77
+	"AS15133":  true, // Edgecast
+	"AS24429":  true, // Alibaba CDN
+	"AS133199": true, // Tencent CDN
+	"AS55967":  true, // Baidu CDN
+}
+
+// cdnKeywords 按运营商名称识别CDN
+var cdnKeywords = []string{"cloudflare", "fastly", "akamai", "cdn77", "edgecast", "cloudfront"}
+
+// ResolveEntryIP 解析节点服务器地址得到入口IP，优先使用IPv4
+func ResolveEntryIP(server string, timeout time.Duration) (string, error) {
+	if ip := net.ParseIP(server); ip != nil {
+		return ip.String(), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, server)
+	if err != nil {
+		return "", err
+	}
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("域名 %s 没有解析记录", server)
+	}
+
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			return addr.IP.String(), nil
+		}
+	}
+	return addrs[0].IP.String(), nil
+}
+
+// isCDNInfo 判断IP是否属于CDN网络
+func isCDNInfo(info *model.IPInfo) bool {
+	if info == nil {
+		return false
+	}
+	if cdnASNs[info.ASN] {
+		return true
+	}
+
+	name := strings.ToLower(info.ISP + " " + info.Org)
+	for _, keyword := range cdnKeywords {
+		if strings.Contains(name, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyRoute 根据入口与出口信息判断线路类型，信息不足时返回空字符串
+func classifyRoute(node *model.ProxyNode) string {
+	if isCDNInfo(node.EntryIPInfo) {
+		return model.RouteCDN
+	}
+	if node.EntryIP == "" || node.OutletIP == "" {
+		return ""
+	}
+	if node.EntryIP == node.OutletIP {
+		return model.RouteDirect
+	}
+
+	// 入口与出口IP不同，但位于同一国家和同一网络时，通常是同一服务器的多个地址
+	entry, exit := node.EntryIPInfo, node.IPInfo
+	if entry != nil && exit != nil && entry.ASN != "" &&
+		entry.ASN == exit.ASN && entry.CountryCode == exit.CountryCode {
+		return model.RouteDirect
+	}
+
+	return model.RouteRelay
+}
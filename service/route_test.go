@@ -0,0 +1,85 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// TestClassifyRoute 根据入口与出口信息判断直连、中转与CDN
+func TestClassifyRoute(t *testing.T) {
+	tests := []struct {
+		name string
+		node *model.ProxyNode
+		want string
+	}{
+		{
+			name: "入口与出口IP相同",
+			node: &model.ProxyNode{EntryIP: "203.0.113.1", OutletIP: "203.0.113.1"},
+			want: model.RouteDirect,
+		},
+		{
+			name: "入口与出口位于同一国家和同一网络",
+			node: &model.ProxyNode{
+				EntryIP: "203.0.113.1", EntryIPInfo: &model.IPInfo{CountryCode: "JP", ASN: "AS2497"},
+				OutletIP: "203.0.113.2", IPInfo: &model.IPInfo{CountryCode: "JP", ASN: "AS2497"},
+			},
+			want: model.RouteDirect,
+		},
+		{
+			name: "入口与出口位于不同网络",
+			node: &model.ProxyNode{
+				EntryIP: "198.51.100.1", EntryIPInfo: &model.IPInfo{CountryCode: "HK", ASN: "AS4134"},
+				OutletIP: "203.0.113.2", IPInfo: &model.IPInfo{CountryCode: "JP", ASN: "AS2497"},
+			},
+			want: model.RouteRelay,
+		},
+		{
+			name: "同一网络但不同国家",
+			node: &model.ProxyNode{
+				EntryIP: "198.51.100.1", EntryIPInfo: &model.IPInfo{CountryCode: "HK", ASN: "AS16509"},
+				OutletIP: "203.0.113.2", IPInfo: &model.IPInfo{CountryCode: "JP", ASN: "AS16509"},
+			},
+			want: model.RouteRelay,
+		},
+		{
+			name: "入口缺少ASN时视为中转",
+			node: &model.ProxyNode{
+				EntryIP: "198.51.100.1", EntryIPInfo: &model.IPInfo{CountryCode: "JP"},
+				OutletIP: "203.0.113.2", IPInfo: &model.IPInfo{CountryCode: "JP"},
+			},
+			want: model.RouteRelay,
+		},
+		{
+			name: "入口位于CDN的ASN",
+			node: &model.ProxyNode{
+				EntryIP: "104.16.0.1", EntryIPInfo: &model.IPInfo{ASN: "AS13335"},
+				OutletIP: "104.16.0.1",
+			},
+			want: model.RouteCDN,
+		},
+		{
+			name: "按运营商名称识别CDN，缺少出口时也能判断",
+			node: &model.ProxyNode{EntryIP: "13.32.0.1", EntryIPInfo: &model.IPInfo{ASN: "AS16509", Org: "Amazon CloudFront"}},
+			want: model.RouteCDN,
+		},
+		{
+			name: "缺少出口IP",
+			node: &model.ProxyNode{EntryIP: "203.0.113.1", EntryIPInfo: &model.IPInfo{ASN: "AS2497"}},
+			want: "",
+		},
+		{
+			name: "缺少入口IP",
+			node: &model.ProxyNode{OutletIP: "203.0.113.1", IPInfo: &model.IPInfo{ASN: "AS2497"}},
+			want: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := classifyRoute(test.node); got != test.want {
+				t.Errorf("线路类型为 %q，期望 %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// nodeLess 比较函数，返回负数表示a排在b前面
+type nodeLess func(a, b *model.ProxyNode) int
+
+// sortKeys 排序键，默认方向为"更优的在前"
+var sortKeys = map[string]nodeLess{
+	"latency": func(a, b *model.ProxyNode) int {
+		// 未测试（延迟为0）的节点排在最后
+		return compareInt(latencyRank(a), latencyRank(b))
+	},
+	"speed": func(a, b *model.ProxyNode) int {
+		return compareInt(b.Speed, a.Speed)
+	},
+	"success_rate": func(a, b *model.ProxyNode) int {
+		return compareInt(b.SuccessRate, a.SuccessRate)
+	},
+	"country": func(a, b *model.ProxyNode) int {
+		return strings.Compare(nodeCountry(a), nodeCountry(b))
+	},
+	"name": func(a, b *model.ProxyNode) int {
+		return strings.Compare(a.Name, b.Name)
+	},
+	"subscription": func(a, b *model.ProxyNode) int {
+		return strings.Compare(a.SubscriptionName, b.SubscriptionName)
+	},
+}
+
+// defaultSortBy 未配置排序键时的排序
+var defaultSortBy = []string{"latency"}
+
+// SelectNodes 按配置排序节点，并按国家、订阅与总数限制数量，返回新的切片
+func SelectNodes(nodes []*model.ProxyNode, cfg config.SelectConfig) []*model.ProxyNode {
+	sortBy := cfg.SortBy
+	if len(sortBy) == 0 {
+		sortBy = defaultSortBy
+	}
+
+	comparators := make([]nodeLess, 0, len(sortBy))
+	for _, key := range sortBy {
+		desc := strings.HasPrefix(key, "-")
+		less, ok := sortKeys[strings.TrimPrefix(key, "-")]
+		if !ok {
+			continue
+		}
+		if desc {
+			asc := less
+			less = func(a, b *model.ProxyNode) int { return asc(b, a) }
+		}
+		comparators = append(comparators, less)
+	}
+
+	sorted := make([]*model.ProxyNode, len(nodes))
+	copy(sorted, nodes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		for _, less := range comparators {
+			if c := less(a, b); c != 0 {
+				return c < 0
+			}
+		}
+		// 保证结果稳定
+		if a.SubscriptionName != b.SubscriptionName {
+			return a.SubscriptionName < b.SubscriptionName
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+
+	countryCounts := make(map[string]int)
+	subscriptionCounts := make(map[string]int)
+	selected := make([]*model.ProxyNode, 0, len(sorted))
+	for _, node := range sorted {
+		if cfg.MaxNodes > 0 && len(selected) >= cfg.MaxNodes {
+			break
+		}
+
+		country := nodeCountry(node)
+		if cfg.PerCountry > 0 && countryCounts[country] >= cfg.PerCountry {
+			continue
+		}
+		if cfg.PerSubscription > 0 && subscriptionCounts[node.SubscriptionID] >= cfg.PerSubscription {
+			continue
+		}
+
+		countryCounts[country]++
+		subscriptionCounts[node.SubscriptionID]++
+		selected = append(selected, node)
+	}
+	return selected
+}
+
+// nodeCountry 节点国家代码，未知时为UN
+func nodeCountry(node *model.ProxyNode) string {
+	if node.IPInfo == nil || node.IPInfo.CountryCode == "" {
+		return "UN"
+	}
+	return node.IPInfo.CountryCode
+}
+
+// latencyRank 延迟排序值，未测试的节点视为最慢
+func latencyRank(node *model.ProxyNode) int {
+	if node.Latency <= 0 {
+		return int(^uint(0) >> 1)
+	}
+	return node.Latency
+}
+
+// compareInt 比较两个整数
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// selectTestNodes 测试节点，名称即ID
+func selectTestNodes() []*model.ProxyNode {
+	node := func(name, country, sub string, latency, speed, successRate int, active bool) *model.ProxyNode {
+		n := &model.ProxyNode{
+			ID: name, Name: name, Active: active,
+			SubscriptionID: "id-" + sub, SubscriptionName: sub,
+			Latency: latency, Speed: speed, SuccessRate: successRate,
+		}
+		if country != "" {
+			n.IPInfo = &model.IPInfo{CountryCode: country}
+		}
+		return n
+	}
+	return []*model.ProxyNode{
+		node("jp-1", "JP", "b", 120, 30, 90, true),
+		node("jp-2", "JP", "a", 80, 50, 100, true),
+		node("jp-3", "JP", "b", 80, 10, 80, true),
+		node("us-1", "US", "a", 200, 80, 100, true),
+		node("us-2", "US", "b", 0, 0, 0, true),
+		node("hk-1", "HK", "a", 50, 20, 70, true),
+		node("xx-1", "", "c", 60, 40, 60, true),
+		node("down", "SG", "c", 10, 90, 0, false),
+	}
+}
+
+// nodeIDs 返回节点ID列表
+func nodeIDs(nodes []*model.ProxyNode) []string {
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+	return ids
+}
+
+// TestSelectNodes 排序键、方向、相同值的稳定顺序与各项数量限制
+func TestSelectNodes(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.SelectConfig
+		want []string
+	}{
+		{
+			name: "默认按延迟排序，未测试的节点在最后，相同延迟按订阅与名称排序",
+			cfg:  config.SelectConfig{},
+			want: []string{"down", "hk-1", "xx-1", "jp-2", "jp-3", "jp-1", "us-1", "us-2"},
+		},
+		{
+			name: "速度从高到低",
+			cfg:  config.SelectConfig{SortBy: []string{"speed"}},
+			want: []string{"down", "us-1", "jp-2", "xx-1", "jp-1", "hk-1", "jp-3", "us-2"},
+		},
+		{
+			name: "成功率从高到低，相同时按订阅与名称",
+			cfg:  config.SelectConfig{SortBy: []string{"success_rate"}},
+			want: []string{"jp-2", "us-1", "jp-1", "jp-3", "hk-1", "xx-1", "us-2", "down"},
+		},
+		{
+			name: "国家后接延迟，未知国家为UN",
+			cfg:  config.SelectConfig{SortBy: []string{"country", "latency"}},
+			want: []string{"hk-1", "jp-2", "jp-3", "jp-1", "down", "xx-1", "us-1", "us-2"},
+		},
+		{
+			name: "名称反向",
+			cfg:  config.SelectConfig{SortBy: []string{"-name"}},
+			want: []string{"xx-1", "us-2", "us-1", "jp-3", "jp-2", "jp-1", "hk-1", "down"},
+		},
+		{
+			name: "订阅后接反向速度，未知排序键被忽略",
+			cfg:  config.SelectConfig{SortBy: []string{"subscription", "unknown", "-speed"}},
+			want: []string{"hk-1", "jp-2", "us-1", "us-2", "jp-3", "jp-1", "xx-1", "down"},
+		},
+		{
+			name: "每个国家最多1个",
+			cfg:  config.SelectConfig{PerCountry: 1},
+			want: []string{"down", "hk-1", "xx-1", "jp-2", "us-1"},
+		},
+		{
+			name: "每个订阅最多2个",
+			cfg:  config.SelectConfig{PerSubscription: 2},
+			want: []string{"down", "hk-1", "xx-1", "jp-2", "jp-3", "jp-1"},
+		},
+		{
+			name: "国家、订阅与总数限制同时生效",
+			cfg:  config.SelectConfig{SortBy: []string{"latency"}, PerCountry: 2, PerSubscription: 2, MaxNodes: 4},
+			want: []string{"down", "hk-1", "xx-1", "jp-2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nodes := selectTestNodes()
+			got := SelectNodes(nodes, test.cfg)
+			if ids := nodeIDs(got); !reflect.DeepEqual(ids, test.want) {
+				t.Errorf("选择结果为 %v，期望 %v", ids, test.want)
+			}
+			if ids := nodeIDs(nodes); ids[0] != "jp-1" || ids[len(ids)-1] != "down" {
+				t.Errorf("SelectNodes修改了输入切片: %v", ids)
+			}
+		})
+	}
+}
+
+// TestSelectNodesInactive 不可用节点在选择前过滤，不占用国家、订阅与总数名额
+func TestSelectNodesInactive(t *testing.T) {
+	cfg := config.SelectConfig{PerSubscription: 1, MaxNodes: 3}
+	got := nodeIDs(SelectNodes(activeNodes(selectTestNodes()), cfg))
+	if want := []string{"hk-1", "xx-1", "jp-3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("选择结果为 %v，期望 %v", got, want)
+	}
+}
@@ -0,0 +1,717 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/nariahlamb/sharesubweb/config"
+)
+
+// singBoxOutbound 将Clash.Meta代理配置转换为sing-box出站，sing-box无法表示的节点返回false
+func singBoxOutbound(proxy map[string]interface{}, tag string) (map[string]interface{}, bool) {
+	outbound := map[string]interface{}{
+		"tag":         tag,
+		"server":      stringField(proxy, "server"),
+		"server_port": intField(proxy, "port"),
+	}
+
+	switch stringField(proxy, "type") {
+	case "ss":
+		outbound["type"] = "shadowsocks"
+		outbound["method"] = stringField(proxy, "cipher")
+		outbound["password"] = stringField(proxy, "password")
+		if !setSingBoxPlugin(outbound, proxy) {
+			return nil, false
+		}
+		if boolField(proxy, "udp-over-tcp") {
+			outbound["udp_over_tcp"] = true
+		}
+		setSingBoxMultiplex(outbound, proxy)
+	case "vmess":
+		outbound["type"] = "vmess"
+		outbound["uuid"] = stringField(proxy, "uuid")
+		outbound["security"] = stringOr(stringField(proxy, "cipher"), "auto")
+		outbound["alter_id"] = intField(proxy, "alterId")
+		if !setSingBoxTransport(outbound, proxy) {
+			return nil, false
+		}
+		if boolField(proxy, "tls") {
+			outbound["tls"] = singBoxTLS(proxy, "servername")
+		}
+		setSingBoxMultiplex(outbound, proxy)
+	case "vless":
+		outbound["type"] = "vless"
+		outbound["uuid"] = stringField(proxy, "uuid")
+		if flow := stringField(proxy, "flow"); flow != "" {
+			// sing-box仅支持xtls-rprx-vision
+			if flow != "xtls-rprx-vision" {
+				return nil, false
+			}
+			outbound["flow"] = flow
+		}
+		if !setSingBoxTransport(outbound, proxy) {
+			return nil, false
+		}
+		if boolField(proxy, "tls") || mapField(proxy, "reality-opts") != nil {
+			outbound["tls"] = singBoxTLS(proxy, "servername")
+		}
+		if encoding := stringField(proxy, "packet-encoding"); encoding != "" {
+			outbound["packet_encoding"] = encoding
+		}
+		setSingBoxMultiplex(outbound, proxy)
+	case "trojan":
+		outbound["type"] = "trojan"
+		outbound["password"] = stringField(proxy, "password")
+		if !setSingBoxTransport(outbound, proxy) {
+			return nil, false
+		}
+		outbound["tls"] = singBoxTLS(proxy, "sni")
+		setSingBoxMultiplex(outbound, proxy)
+	case "hysteria":
+		outbound["type"] = "hysteria"
+		outbound["up_mbps"] = parseMbps(proxy["up"])
+		outbound["down_mbps"] = parseMbps(proxy["down"])
+		if auth := stringOr(stringField(proxy, "auth-str"), stringField(proxy, "auth")); auth != "" {
+			outbound["auth_str"] = auth
+		}
+		if obfs := stringField(proxy, "obfs"); obfs != "" {
+			outbound["obfs"] = obfs
+		}
+		// sing-box的hysteria只支持UDP协议
+		if protocol := stringField(proxy, "protocol"); protocol != "" && protocol != "udp" {
+			return nil, false
+		}
+		outbound["tls"] = singBoxTLS(proxy, "sni")
+	case "hysteria2":
+		outbound["type"] = "hysteria2"
+		outbound["password"] = stringField(proxy, "password")
+		if up := parseMbps(proxy["up"]); up > 0 {
+			outbound["up_mbps"] = up
+		}
+		if down := parseMbps(proxy["down"]); down > 0 {
+			outbound["down_mbps"] = down
+		}
+		if obfs := stringField(proxy, "obfs"); obfs != "" {
+			outbound["obfs"] = map[string]interface{}{
+				"type":     obfs,
+				"password": stringField(proxy, "obfs-password"),
+			}
+		}
+		outbound["tls"] = singBoxTLS(proxy, "sni")
+	case "tuic":
+		// sing-box只支持TUIC v5，v4使用token认证
+		if stringField(proxy, "uuid") == "" || stringField(proxy, "token") != "" {
+			return nil, false
+		}
+		outbound["type"] = "tuic"
+		outbound["uuid"] = stringField(proxy, "uuid")
+		outbound["password"] = stringField(proxy, "password")
+		if cc := stringField(proxy, "congestion-controller"); cc != "" {
+			outbound["congestion_control"] = cc
+		}
+		if mode := stringField(proxy, "udp-relay-mode"); mode != "" {
+			outbound["udp_relay_mode"] = mode
+		}
+		if boolField(proxy, "reduce-rtt") {
+			outbound["zero_rtt_handshake"] = true
+		}
+		outbound["tls"] = singBoxTLS(proxy, "sni")
+	case "wireguard":
+		outbound["type"] = "wireguard"
+		var addresses []string
+		if ip := stringField(proxy, "ip"); ip != "" {
+			addresses = append(addresses, withPrefix(ip, "/32"))
+		}
+		if ipv6 := stringField(proxy, "ipv6"); ipv6 != "" {
+			addresses = append(addresses, withPrefix(ipv6, "/128"))
+		}
+		if len(addresses) == 0 {
+			return nil, false
+		}
+		outbound["local_address"] = addresses
+		outbound["private_key"] = stringField(proxy, "private-key")
+		outbound["peer_public_key"] = stringField(proxy, "public-key")
+		if psk := stringField(proxy, "pre-shared-key"); psk != "" {
+			outbound["pre_shared_key"] = psk
+		}
+		if reserved := intListField(proxy, "reserved"); len(reserved) > 0 {
+			outbound["reserved"] = reserved
+		}
+		if mtu := intField(proxy, "mtu"); mtu > 0 {
+			outbound["mtu"] = mtu
+		}
+	case "socks5":
+		// sing-box的socks出站不支持TLS
+		if boolField(proxy, "tls") {
+			return nil, false
+		}
+		outbound["type"] = "socks"
+		outbound["version"] = "5"
+		setSingBoxAuth(outbound, proxy)
+	case "http":
+		outbound["type"] = "http"
+		setSingBoxAuth(outbound, proxy)
+		if boolField(proxy, "tls") {
+			outbound["tls"] = singBoxTLS(proxy, "sni")
+		}
+	default:
+		// ssr与snell等sing-box不支持的类型
+		return nil, false
+	}
+
+	return outbound, true
+}
+
+// sing-box配置中固定的出站标签
+const (
+	singBoxProxyTag  = "Proxy"
+	singBoxAutoTag   = "Auto"
+	singBoxDirectTag = "direct"
+	singBoxBlockTag  = "block"
+	singBoxDNSTag    = "dns-out"
+)
+
+// singBoxTarget 目标sing-box版本，决定生成的配置结构
+type singBoxTarget struct {
+	minor int // 1.x中的x
+}
+
+// newSingBoxTarget 解析目标版本，为空或无法解析时使用1.11
+func newSingBoxTarget(version string) singBoxTarget {
+	minor, err := strconv.Atoi(strings.TrimPrefix(version, "1."))
+	if err != nil || !strings.HasPrefix(version, "1.") {
+		minor = 11
+	}
+	return singBoxTarget{minor: minor}
+}
+
+// tunAddress 1.10起TUN入站使用address代替inet4_address与inet6_address
+func (t singBoxTarget) tunAddress() bool {
+	return t.minor >= 10
+}
+
+// ruleActions 1.11起使用规则动作代替入站嗅探字段与block、dns特殊出站，WireGuard改为endpoint
+func (t singBoxTarget) ruleActions() bool {
+	return t.minor >= 11
+}
+
+// dnsServerTypes 1.12起DNS服务器使用type与server字段，节点域名通过default_domain_resolver解析
+func (t singBoxTarget) dnsServerTypes() bool {
+	return t.minor >= 12
+}
+
+// buildSingBoxConfig 生成完整的sing-box配置，outbounds与endpoints为节点，tags为节点标签
+func buildSingBoxConfig(cfg config.SingBoxOutputConfig, target singBoxTarget, outbounds, endpoints []map[string]interface{}, tags []string) SingBoxConfig {
+	testURL := stringOr(cfg.TestURL, "http://www.gstatic.com/generate_204")
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = 300
+	}
+	mixedPort := cfg.MixedPort
+	if mixedPort <= 0 {
+		mixedPort = 7890
+	}
+
+	// 代理选择与自动测速出站，没有节点时只能直连
+	selector := map[string]interface{}{
+		"type":      "selector",
+		"tag":       singBoxProxyTag,
+		"outbounds": append(append([]string{}, tags...), singBoxDirectTag),
+	}
+	groups := []map[string]interface{}{selector}
+	if len(tags) > 0 {
+		selector["outbounds"] = append([]string{singBoxAutoTag}, selector["outbounds"].([]string)...)
+		selector["default"] = singBoxAutoTag
+		groups = append(groups, map[string]interface{}{
+			"type":      "urltest",
+			"tag":       singBoxAutoTag,
+			"outbounds": tags,
+			"url":       testURL,
+			"interval":  fmt.Sprintf("%ds", interval),
+			"tolerance": 50,
+		})
+	}
+
+	allOutbounds := append(groups, outbounds...)
+	allOutbounds = append(allOutbounds, map[string]interface{}{"type": "direct", "tag": singBoxDirectTag})
+	if !target.ruleActions() {
+		allOutbounds = append(allOutbounds,
+			map[string]interface{}{"type": "block", "tag": singBoxBlockTag},
+			map[string]interface{}{"type": "dns", "tag": singBoxDNSTag})
+	}
+
+	return SingBoxConfig{
+		Log:          map[string]interface{}{"level": "info", "timestamp": true},
+		DNS:          singBoxDNS(cfg, target),
+		Inbounds:     singBoxInbounds(cfg, target, mixedPort),
+		Outbounds:    allOutbounds,
+		Endpoints:    endpoints,
+		Route:        singBoxRoute(cfg, target, len(tags) > 0),
+		Experimental: map[string]interface{}{"cache_file": map[string]interface{}{"enabled": true}},
+	}
+}
+
+// singBoxInbounds 生成本地混合代理入站与可选的TUN入站
+func singBoxInbounds(cfg config.SingBoxOutputConfig, target singBoxTarget, mixedPort int) []map[string]interface{} {
+	inbounds := []map[string]interface{}{{
+		"type":        "mixed",
+		"tag":         "mixed-in",
+		"listen":      "127.0.0.1",
+		"listen_port": mixedPort,
+	}}
+	if cfg.Tun {
+		tun := map[string]interface{}{
+			"type":         "tun",
+			"tag":          "tun-in",
+			"auto_route":   true,
+			"strict_route": true,
+		}
+		if target.tunAddress() {
+			tun["address"] = []string{"172.19.0.1/30", "fdfe:dcba:9876::1/126"}
+		} else {
+			tun["inet4_address"] = "172.19.0.1/30"
+			tun["inet6_address"] = "fdfe:dcba:9876::1/126"
+		}
+		inbounds = append(inbounds, tun)
+	}
+
+	// 1.11之前在入站上开启嗅探，之后使用sniff规则动作
+	if !target.ruleActions() {
+		for _, inbound := range inbounds {
+			inbound["sniff"] = true
+		}
+	}
+	return inbounds
+}
+
+// singBoxDNS 生成DNS配置，默认经代理查询，节点域名使用直连DNS解析
+func singBoxDNS(cfg config.SingBoxOutputConfig, target singBoxTarget) map[string]interface{} {
+	remote := stringOr(cfg.DNSRemote, "https://1.1.1.1/dns-query")
+	direct := stringOr(cfg.DNSDirect, "https://223.5.5.5/dns-query")
+
+	dns := map[string]interface{}{
+		"servers": []map[string]interface{}{
+			singBoxDNSServer("remote", remote, singBoxProxyTag, target),
+			singBoxDNSServer("local", direct, singBoxDirectTag, target),
+		},
+		"final": "remote",
+	}
+	// 1.12起节点域名由route.default_domain_resolver解析
+	if !target.dnsServerTypes() {
+		dns["rules"] = []map[string]interface{}{{"outbound": "any", "server": "local"}}
+	}
+	return dns
+}
+
+// singBoxDNSServer 生成DNS服务器，address支持 https://、tls://、quic://、h3://、udp://、tcp://、IP地址与local
+func singBoxDNSServer(tag, address, detour string, target singBoxTarget) map[string]interface{} {
+	if !target.dnsServerTypes() {
+		server := map[string]interface{}{"tag": tag, "address": address, "detour": detour}
+		if host := dnsServerHost(address); host != "" && net.ParseIP(host) == nil {
+			server["address_resolver"] = "local"
+		}
+		return server
+	}
+
+	if address == "local" {
+		return map[string]interface{}{"type": "local", "tag": tag}
+	}
+	server := map[string]interface{}{"type": "udp", "tag": tag}
+	host := address
+	if u, err := url.Parse(address); err == nil && u.Scheme != "" && u.Host != "" {
+		server["type"] = u.Scheme
+		host = u.Host
+		if (u.Scheme == "https" || u.Scheme == "h3") && u.Path != "" && u.Path != "/dns-query" {
+			server["path"] = u.Path
+		}
+	}
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		host = h
+		if p, err := strconv.Atoi(port); err == nil {
+			server["server_port"] = p
+		}
+	}
+	server["server"] = host
+	if net.ParseIP(host) == nil && tag != "local" {
+		server["domain_resolver"] = "local"
+	}
+	// 1.12起直连无需指定detour
+	if detour != singBoxDirectTag {
+		server["detour"] = detour
+	}
+	return server
+}
+
+// dnsServerHost 读取DNS服务器地址中的主机名
+func dnsServerHost(address string) string {
+	if address == "local" {
+		return ""
+	}
+	if u, err := url.Parse(address); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
+
+// singBoxRoute 生成路由配置：DNS劫持、私有地址直连与规则集，auto表示是否生成了Auto出站
+func singBoxRoute(cfg config.SingBoxOutputConfig, target singBoxTarget, auto bool) map[string]interface{} {
+	var rules []map[string]interface{}
+	if target.ruleActions() {
+		rules = append(rules,
+			map[string]interface{}{"action": "sniff"},
+			map[string]interface{}{"protocol": "dns", "action": "hijack-dns"})
+	} else {
+		rules = append(rules, map[string]interface{}{"protocol": "dns", "outbound": singBoxDNSTag})
+	}
+	rules = append(rules, map[string]interface{}{"ip_is_private": true, "outbound": singBoxDirectTag})
+
+	ruleSets := make([]map[string]interface{}, 0, len(cfg.RuleSets))
+	for _, rs := range cfg.RuleSets {
+		ruleSet := map[string]interface{}{
+			"type":   rs.Type,
+			"tag":    rs.Tag,
+			"format": stringOr(rs.Format, "binary"),
+		}
+		if rs.Type == "remote" {
+			ruleSet["url"] = rs.URL
+			ruleSet["download_detour"] = singBoxProxyTag
+		} else {
+			ruleSet["path"] = rs.Path
+		}
+		ruleSets = append(ruleSets, ruleSet)
+		rules = append(rules, singBoxRouteRule(map[string]interface{}{"rule_set": rs.Tag}, singBoxRouteOutbound(rs.Outbound, auto), target))
+	}
+
+	route := map[string]interface{}{"auto_detect_interface": true}
+	// 1.11起没有block出站，未匹配的流量由不带条件的reject规则拒绝
+	if final := singBoxRouteOutbound(cfg.Final, auto); final == singBoxBlockTag && target.ruleActions() {
+		rules = append(rules, map[string]interface{}{"action": "reject"})
+	} else {
+		route["final"] = final
+	}
+	route["rules"] = rules
+	if len(ruleSets) > 0 {
+		route["rule_set"] = ruleSets
+	}
+	if target.dnsServerTypes() {
+		route["default_domain_resolver"] = "local"
+	}
+	return route
+}
+
+// singBoxRouteOutbound 路由使用的出站，默认Proxy，没有节点时不生成Auto出站，改用Proxy
+func singBoxRouteOutbound(outbound string, auto bool) string {
+	outbound = stringOr(outbound, singBoxProxyTag)
+	if outbound == singBoxAutoTag && !auto {
+		return singBoxProxyTag
+	}
+	return outbound
+}
+
+// singBoxRouteRule 设置规则命中后的出站，block在1.11起使用reject动作
+func singBoxRouteRule(rule map[string]interface{}, outbound string, target singBoxTarget) map[string]interface{} {
+	if outbound == singBoxBlockTag && target.ruleActions() {
+		rule["action"] = "reject"
+		return rule
+	}
+	rule["outbound"] = outbound
+	return rule
+}
+
+// singBoxEndpoint 将WireGuard出站转换为1.11起使用的endpoint
+func singBoxEndpoint(outbound map[string]interface{}) map[string]interface{} {
+	peer := map[string]interface{}{
+		"address":     outbound["server"],
+		"port":        outbound["server_port"],
+		"public_key":  outbound["peer_public_key"],
+		"allowed_ips": []string{"0.0.0.0/0", "::/0"},
+	}
+	endpoint := map[string]interface{}{
+		"type":        "wireguard",
+		"tag":         outbound["tag"],
+		"address":     outbound["local_address"],
+		"private_key": outbound["private_key"],
+		"peers":       []map[string]interface{}{peer},
+	}
+	if psk, ok := outbound["pre_shared_key"]; ok {
+		peer["pre_shared_key"] = psk
+	}
+	if reserved, ok := outbound["reserved"]; ok {
+		peer["reserved"] = reserved
+	}
+	if mtu, ok := outbound["mtu"]; ok {
+		endpoint["mtu"] = mtu
+	}
+	return endpoint
+}
+
+// uniqueTag 标签重复或与固定出站冲突时添加序号
+func uniqueTag(used map[string]bool, tag string) string {
+	unique := tag
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s %d", tag, i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// singBoxTLS 生成TLS配置，sniKey为Clash中服务器名称的字段名
+func singBoxTLS(proxy map[string]interface{}, sniKey string) map[string]interface{} {
+	tls := map[string]interface{}{"enabled": true}
+	if sni := stringField(proxy, sniKey); sni != "" {
+		tls["server_name"] = sni
+	}
+	if boolField(proxy, "skip-cert-verify") {
+		tls["insecure"] = true
+	}
+	if alpn := listField(proxy, "alpn"); len(alpn) > 0 {
+		tls["alpn"] = alpn
+	}
+	if fingerprint := stringField(proxy, "client-fingerprint"); fingerprint != "" {
+		tls["utls"] = map[string]interface{}{
+			"enabled":     true,
+			"fingerprint": fingerprint,
+		}
+	}
+	if reality := mapField(proxy, "reality-opts"); reality != nil {
+		tls["reality"] = map[string]interface{}{
+			"enabled":    true,
+			"public_key": stringField(reality, "public-key"),
+			"short_id":   stringField(reality, "short-id"),
+		}
+		// Reality需要uTLS
+		if _, ok := tls["utls"]; !ok {
+			tls["utls"] = map[string]interface{}{
+				"enabled":     true,
+				"fingerprint": "chrome",
+			}
+		}
+	}
+	return tls
+}
+
+// setSingBoxTransport 设置传输层，sing-box不支持的传输方式返回false
+func setSingBoxTransport(outbound, proxy map[string]interface{}) bool {
+	switch network := stringField(proxy, "network"); network {
+	case "", "tcp":
+		return true
+	case "ws":
+		// ws-opts中的v2ray-http-upgrade对应sing-box的httpupgrade传输
+		opts := mapField(proxy, "ws-opts")
+		if opts == nil {
+			opts = map[string]interface{}{}
+		}
+		transport := map[string]interface{}{"type": "ws"}
+		if boolField(opts, "v2ray-http-upgrade") {
+			transport["type"] = "httpupgrade"
+		}
+		if path := stringField(opts, "path"); path != "" {
+			transport["path"] = path
+		}
+		if headers := mapField(opts, "headers"); headers != nil {
+			if transport["type"] == "httpupgrade" {
+				if host := stringField(headers, "Host"); host != "" {
+					transport["host"] = host
+				}
+			} else {
+				transport["headers"] = headers
+			}
+		}
+		if maxEarlyData := intField(opts, "max-early-data"); maxEarlyData > 0 && transport["type"] == "ws" {
+			transport["max_early_data"] = maxEarlyData
+			transport["early_data_header_name"] = stringOr(stringField(opts, "early-data-header-name"), "Sec-WebSocket-Protocol")
+		}
+		outbound["transport"] = transport
+	case "grpc":
+		transport := map[string]interface{}{"type": "grpc"}
+		if opts := mapField(proxy, "grpc-opts"); opts != nil {
+			transport["service_name"] = stringField(opts, "grpc-service-name")
+		}
+		outbound["transport"] = transport
+	case "h2":
+		// Clash的h2传输对应sing-box的http传输，TCP的HTTP伪装（network: http）sing-box不支持
+		transport := map[string]interface{}{"type": "http"}
+		if opts := mapField(proxy, "h2-opts"); opts != nil {
+			if hosts := listField(opts, "host"); len(hosts) > 0 {
+				transport["host"] = hosts
+			}
+			if path := stringField(opts, "path"); path != "" {
+				transport["path"] = path
+			}
+		}
+		outbound["transport"] = transport
+	default:
+		return false
+	}
+	return true
+}
+
+// setSingBoxPlugin 设置shadowsocks插件，不支持的插件返回false
+func setSingBoxPlugin(outbound, proxy map[string]interface{}) bool {
+	plugin, options, ok := ssPlugin(proxy)
+	if !ok {
+		return false
+	}
+	if plugin != "" {
+		outbound["plugin"] = plugin
+		outbound["plugin_opts"] = options
+	}
+	return true
+}
+
+// ssPlugin 将Clash的shadowsocks插件转换为SIP003插件名称与参数，仅支持obfs-local与v2ray-plugin
+func ssPlugin(proxy map[string]interface{}) (string, string, bool) {
+	plugin := stringField(proxy, "plugin")
+	if plugin == "" {
+		return "", "", true
+	}
+
+	opts := mapField(proxy, "plugin-opts")
+	if opts == nil {
+		opts = map[string]interface{}{}
+	}
+	switch plugin {
+	case "obfs":
+		return "obfs-local", pluginOptions(
+			"obfs="+stringOr(stringField(opts, "mode"), "http"),
+			prefixed("obfs-host=", stringField(opts, "host")),
+		), true
+	case "v2ray-plugin":
+		if mode := stringField(opts, "mode"); mode != "" && mode != "websocket" {
+			return "", "", false
+		}
+		options := []string{}
+		if boolField(opts, "tls") {
+			options = append(options, "tls")
+		}
+		options = append(options,
+			prefixed("host=", stringField(opts, "host")),
+			prefixed("path=", stringField(opts, "path")),
+		)
+		if boolField(opts, "mux") {
+			options = append(options, "mux=1")
+		}
+		return "v2ray-plugin", pluginOptions(options...), true
+	}
+	return "", "", false
+}
+
+// setSingBoxMultiplex 设置多路复用
+func setSingBoxMultiplex(outbound, proxy map[string]interface{}) {
+	smux := mapField(proxy, "smux")
+	if smux == nil || !boolField(smux, "enabled") {
+		return
+	}
+	multiplex := map[string]interface{}{
+		"enabled":  true,
+		"protocol": stringOr(stringField(smux, "protocol"), "h2mux"),
+	}
+	if maxConnections := intField(smux, "max-connections"); maxConnections > 0 {
+		multiplex["max_connections"] = maxConnections
+	}
+	outbound["multiplex"] = multiplex
+}
+
+// setSingBoxAuth 设置用户名与密码
+func setSingBoxAuth(outbound, proxy map[string]interface{}) {
+	if username := stringField(proxy, "username"); username != "" {
+		outbound["username"] = username
+	}
+	if password := stringField(proxy, "password"); password != "" {
+		outbound["password"] = password
+	}
+}
+
+// pluginOptions 拼接插件参数，忽略空项
+func pluginOptions(options ...string) string {
+	return joinOptions(";", options...)
+}
+
+// prefixed 值非空时添加前缀
+func prefixed(prefix, value string) string {
+	if value == "" {
+		return ""
+	}
+	return prefix + value
+}
+
+// stringOr 值为空时使用默认值
+func stringOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// withPrefix 地址未带前缀长度时补充
+func withPrefix(address, prefix string) string {
+	if strings.Contains(address, "/") {
+		return address
+	}
+	return address + prefix
+}
+
+// parseMbps 解析带宽，支持数字与 "30 Mbps" 形式
+func parseMbps(value interface{}) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case string:
+		fields := strings.Fields(strings.ToLower(v))
+		if len(fields) == 0 {
+			return 0
+		}
+		number := strings.TrimRight(fields[0], "abcdefghijklmnopqrstuvwxyz")
+		result, _ := strconv.Atoi(number)
+		return result
+	}
+	return 0
+}
+
+// mapField 读取映射字段
+func mapField(m map[string]interface{}, key string) map[string]interface{} {
+	value, _ := m[key].(map[string]interface{})
+	return value
+}
+
+// listField 读取字符串列表字段，兼容逗号分隔的字符串
+func listField(m map[string]interface{}, key string) []string {
+	switch value := m[key].(type) {
+	case []string:
+		return value
+	case []interface{}:
+		result := make([]string, 0, len(value))
+		for _, item := range value {
+			result = append(result, fmt.Sprint(item))
+		}
+		return result
+	case string:
+		if value == "" {
+			return nil
+		}
+		return strings.Split(value, ",")
+	}
+	return nil
+}
+
+// intListField 读取整数列表字段，兼容逗号分隔的字符串
+func intListField(m map[string]interface{}, key string) []int {
+	values := listField(m, key)
+	result := make([]int, 0, len(values))
+	for _, value := range values {
+		number, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return nil
+		}
+		result = append(result, number)
+	}
+	return result
+}
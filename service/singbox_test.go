@@ -0,0 +1,173 @@
+package service
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// TestSingBoxConfigGolden 每个目标版本生成的完整配置与golden文件一致，且引用的出站标签均存在
+func TestSingBoxConfigGolden(t *testing.T) {
+	var nodes []*model.ProxyNode
+	for _, input := range []string{"vmess.yaml", "wireguard.yaml"} {
+		_, parsed := parseClashTestdata(t, filepath.Join("testdata", "clash", input))
+		nodes = append(nodes, parsed...)
+	}
+
+	for _, version := range config.SingBoxVersions {
+		t.Run(version, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.NodeProcess.Rename.Enable = true
+			cfg.Output.SingBox = config.SingBoxOutputConfig{
+				Version: version,
+				Tun:     true,
+				RuleSets: []config.SingBoxRuleSetConfig{
+					{Tag: "geosite-cn", Type: "remote", URL: "https://example.com/geosite-cn.srs", Outbound: "direct"},
+					{Tag: "ads", Type: "local", Format: "source", Path: "ads.json", Outbound: "block"},
+				},
+			}
+
+			output, err := NewOutputGenerator(cfg).GenerateSingBoxConfig(nodes)
+			if err != nil {
+				t.Fatalf("生成sing-box配置失败: %v", err)
+			}
+			checkSingBoxTags(t, []byte(output))
+			checkGolden(t, filepath.Join("testdata", "singbox", version+".golden"), []byte(output+"\n"))
+		})
+	}
+}
+
+// checkSingBoxTags 检查代理组、路由与DNS引用的标签均已定义
+func checkSingBoxTags(t *testing.T, data []byte) {
+	t.Helper()
+	var cfg struct {
+		DNS struct {
+			Servers []map[string]interface{} `json:"servers"`
+		} `json:"dns"`
+		Outbounds []map[string]interface{} `json:"outbounds"`
+		Endpoints []map[string]interface{} `json:"endpoints"`
+		Route     struct {
+			Rules []map[string]interface{} `json:"rules"`
+			Final string                   `json:"final"`
+		} `json:"route"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Fatalf("解析sing-box配置失败: %v", err)
+	}
+
+	tags := make(map[string]bool)
+	for _, outbound := range append(cfg.Outbounds, cfg.Endpoints...) {
+		tag := outbound["tag"].(string)
+		if tags[tag] {
+			t.Errorf("标签重复: %s", tag)
+		}
+		tags[tag] = true
+	}
+
+	var refs []interface{}
+	for _, outbound := range cfg.Outbounds {
+		if members, ok := outbound["outbounds"].([]interface{}); ok {
+			refs = append(refs, members...)
+		}
+	}
+	for _, rule := range cfg.Route.Rules {
+		if outbound, ok := rule["outbound"]; ok {
+			refs = append(refs, outbound)
+		}
+	}
+	for _, server := range cfg.DNS.Servers {
+		if detour, ok := server["detour"]; ok {
+			refs = append(refs, detour)
+		}
+	}
+	if cfg.Route.Final != "" {
+		refs = append(refs, cfg.Route.Final)
+	}
+	for _, ref := range refs {
+		if !tags[ref.(string)] {
+			t.Errorf("引用了不存在的出站: %v", ref)
+		}
+	}
+}
+
+// TestSingBoxOutboundTags 未启用重命名时出站标签同样使用节点名称，重复或与固定出站冲突时添加序号
+func TestSingBoxOutboundTags(t *testing.T) {
+	_, parsed := parseClashTestdata(t, filepath.Join("testdata", "clash", "vmess.yaml"))
+	var nodes []*model.ProxyNode
+	for _, name := range []string{"香港 01", "香港 01", singBoxProxyTag} {
+		node := *parsed[0]
+		node.Name = name
+		nodes = append(nodes, &node)
+	}
+
+	output, err := NewOutputGenerator(&config.Config{}).GenerateSingBoxConfig(nodes)
+	if err != nil {
+		t.Fatalf("生成sing-box配置失败: %v", err)
+	}
+	var singBox struct {
+		Outbounds []map[string]interface{} `json:"outbounds"`
+	}
+	if err := json.Unmarshal([]byte(output), &singBox); err != nil {
+		t.Fatal(err)
+	}
+	var tags []string
+	for _, outbound := range singBox.Outbounds {
+		if outbound["type"] == "vmess" {
+			tags = append(tags, outbound["tag"].(string))
+		}
+	}
+	want := []string{singBoxProxyTag + " 2", "香港 01", "香港 01 2"}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("出站标签为 %q，期望 %q", tags, want)
+	}
+}
+
+// TestSingBoxRouteOutbounds 没有节点时Auto改用Proxy，final为block时1.11起使用reject规则
+func TestSingBoxRouteOutbounds(t *testing.T) {
+	for _, version := range []string{"1.10", "1.11"} {
+		t.Run(version, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Output.SingBox = config.SingBoxOutputConfig{
+				Version: version,
+				RuleSets: []config.SingBoxRuleSetConfig{
+					{Tag: "streaming", Type: "remote", URL: "https://example.com/streaming.srs", Outbound: "Auto"},
+				},
+				Final: "block",
+			}
+
+			output, err := NewOutputGenerator(cfg).GenerateSingBoxConfig(nil)
+			if err != nil {
+				t.Fatalf("生成sing-box配置失败: %v", err)
+			}
+			checkSingBoxTags(t, []byte(output))
+
+			var singBox struct {
+				Route struct {
+					Rules []map[string]interface{} `json:"rules"`
+					Final string                   `json:"final"`
+				} `json:"route"`
+			}
+			if err := json.Unmarshal([]byte(output), &singBox); err != nil {
+				t.Fatal(err)
+			}
+			rules := singBox.Route.Rules
+			for _, rule := range rules {
+				if rule["rule_set"] == "streaming" && rule["outbound"] != singBoxProxyTag {
+					t.Errorf("规则集的出站为 %v，期望 %s", rule["outbound"], singBoxProxyTag)
+				}
+			}
+			last := rules[len(rules)-1]
+			if version == "1.10" {
+				if singBox.Route.Final != singBoxBlockTag {
+					t.Errorf("final为 %q，期望 %s", singBox.Route.Final, singBoxBlockTag)
+				}
+			} else if singBox.Route.Final != "" || len(last) != 1 || last["action"] != "reject" {
+				t.Errorf("final为 %q，最后一条规则为 %v，期望使用reject规则", singBox.Route.Final, last)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// GenerateStashConfig 生成Stash配置，Stash使用Clash的配置结构，部分协议字段与Clash.Meta不同
+func (g *OutputGenerator) GenerateStashConfig(nodes []*model.ProxyNode) (string, error) {
+	return g.generateClashYAML(nodes, stashProxy, false)
+}
+
+// stashProxy 将Clash.Meta代理配置转换为Stash的代理配置，Stash无法表示的节点返回false
+func stashProxy(proxy map[string]interface{}) (map[string]interface{}, bool) {
+	if !clashSupported(proxy) {
+		return nil, false
+	}
+
+	switch stringField(proxy, "type") {
+	case "hysteria":
+		setStashBandwidth(proxy)
+	case "hysteria2":
+		// Stash的Hysteria2密码字段为auth
+		if password, ok := proxy["password"]; ok {
+			proxy["auth"] = password
+			delete(proxy, "password")
+		}
+		setStashBandwidth(proxy)
+	case "tuic":
+		if _, ok := proxy["version"]; !ok {
+			proxy["version"] = 5
+			if stringField(proxy, "token") != "" {
+				proxy["version"] = 4
+			}
+		}
+	case "wireguard":
+		if psk, ok := proxy["pre-shared-key"]; ok {
+			proxy["preshared-key"] = psk
+			delete(proxy, "pre-shared-key")
+		}
+	case "vless":
+		// Stash只支持xtls-rprx-vision流控
+		if flow := stringField(proxy, "flow"); flow != "" && flow != "xtls-rprx-vision" {
+			return nil, false
+		}
+	}
+	return proxy, true
+}
+
+// setStashBandwidth Stash的带宽字段为up-speed与down-speed，单位为Mbps的整数
+func setStashBandwidth(proxy map[string]interface{}) {
+	for key, stashKey := range map[string]string{"up": "up-speed", "down": "down-speed"} {
+		value, ok := proxy[key]
+		if !ok {
+			continue
+		}
+		delete(proxy, key)
+		if mbps := parseMbps(value); mbps > 0 {
+			proxy[stashKey] = mbps
+		}
+	}
+}
@@ -1,12 +1,12 @@
 package service
 
 import (
-	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -14,6 +14,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/nariahlamb/sharesubweb/config"
 	"github.com/nariahlamb/sharesubweb/model"
+	yaml "gopkg.in/yaml.v3"
 )
 
 // SubscriptionService 订阅服务
@@ -56,14 +57,33 @@ func (s *SubscriptionService) GetSubscriptions() []*model.Subscription {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 	
+	return s.sortedSubscriptions()
+}
+
+// sortedSubscriptions 按名称排序的订阅列表，保证节点顺序稳定，调用方需持有锁
+func (s *SubscriptionService) sortedSubscriptions() []*model.Subscription {
 	subs := make([]*model.Subscription, 0, len(s.subscriptions))
 	for _, sub := range s.subscriptions {
 		subs = append(subs, sub)
 	}
+	sort.Slice(subs, func(i, j int) bool {
+		if subs[i].Name != subs[j].Name {
+			return subs[i].Name < subs[j].Name
+		}
+		return subs[i].ID < subs[j].ID
+	})
 	
 	return subs
 }
 
+// bindNodes 记录节点所属订阅
+func bindNodes(sub *model.Subscription, nodes []*model.ProxyNode) {
+	for _, node := range nodes {
+		node.SubscriptionID = sub.ID
+		node.SubscriptionName = sub.Name
+	}
+}
+
 // GetSubscription 获取指定订阅
 func (s *SubscriptionService) GetSubscription(id string) (*model.Subscription, error) {
 	s.mutex.RLock()
@@ -145,34 +165,57 @@ func (s *SubscriptionService) FetchSubscriptionContent(sub *model.Subscription)
 		return errors.New("订阅地址为空")
 	}
 	
+	body, header, err := fetchURL(sub.URL)
+	if err != nil {
+		return err
+	}
+	
+	// 记录订阅的流量信息
+	s.setSubscriptionUserinfo(sub, header.Get("subscription-userinfo"))
+	
+	return s.parseSubscription(sub, body)
+}
+
+// maxSubscriptionSize 订阅内容与模板的大小上限
+const maxSubscriptionSize = 32 << 20
+
+// fetchURL 下载订阅或模板，返回响应内容与响应头
+func fetchURL(url string) ([]byte, http.Header, error) {
 	// 发送HTTP请求
 	client := &http.Client{
 		Timeout: time.Second * 30,
 	}
 	
-	req, err := http.NewRequest("GET", sub.URL, nil)
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
 	
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 	
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP请求错误: %d", resp.StatusCode)
+		return nil, nil, fmt.Errorf("HTTP请求错误: %d", resp.StatusCode)
 	}
 	
-	body, err := ioutil.ReadAll(resp.Body)
+	// 多读取一个字节以判断是否超出上限
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSubscriptionSize+1))
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	
-	// 解析订阅
+	if len(body) > maxSubscriptionSize {
+		return nil, nil, fmt.Errorf("订阅内容超过 %d MB", maxSubscriptionSize>>20)
+	}
+	return body, resp.Header, nil
+}
+
+// parseSubscription 按订阅类型解析订阅内容
+func (s *SubscriptionService) parseSubscription(sub *model.Subscription, body []byte) error {
 	switch sub.Type {
 	case "clash":
 		return s.parseClashSubscription(sub, body)
@@ -183,13 +226,11 @@ func (s *SubscriptionService) FetchSubscriptionContent(sub *model.Subscription)
 	}
 }
 
-// 解析Clash订阅
+// 解析Clash订阅，支持YAML与JSON格式
 func (s *SubscriptionService) parseClashSubscription(sub *model.Subscription, data []byte) error {
 	var clashConfig map[string]interface{}
-	if err := json.Unmarshal(data, &clashConfig); err != nil {
-		// 尝试解析YAML
-		// 注意：这里简化处理，实际上需要用YAML解析库
-		return errors.New("无法解析Clash配置")
+	if err := yaml.Unmarshal(data, &clashConfig); err != nil {
+		return fmt.Errorf("无法解析Clash配置: %v", err)
 	}
 	
 	// 获取代理列表
@@ -206,66 +247,12 @@ func (s *SubscriptionService) parseClashSubscription(sub *model.Subscription, da
 			continue
 		}
 		
-		node := &model.ProxyNode{
-			ID:       uuid.New().String(),
-			RawData:  proxyMap,
-			LastCheck: time.Time{},
-			APIConnectivity: make(map[string]bool),
-		}
-		
-		// 基本信息
-		if name, ok := proxyMap["name"].(string); ok {
-			node.Name = name
-		}
-		
-		if server, ok := proxyMap["server"].(string); ok {
-			node.Server = server
-		}
-		
-		if port, ok := proxyMap["port"].(float64); ok {
-			node.Port = int(port)
-		}
-		
-		if nodeType, ok := proxyMap["type"].(string); ok {
-			node.Type = nodeType
-		}
-		
-		// 根据类型解析特定字段
-		switch node.Type {
-		case "ss":
-			if password, ok := proxyMap["password"].(string); ok {
-				node.Password = password
-			}
-			if cipher, ok := proxyMap["cipher"].(string); ok {
-				node.Cipher = cipher
-			}
-		case "vmess":
-			if uuid, ok := proxyMap["uuid"].(string); ok {
-				node.UUID = uuid
-			}
-			if cipher, ok := proxyMap["cipher"].(string); ok {
-				node.Cipher = cipher
-			}
-			if network, ok := proxyMap["network"].(string); ok {
-				node.Network = network
-			}
-			if tls, ok := proxyMap["tls"].(bool); ok {
-				node.TLS = tls
-			}
-		case "trojan":
-			if password, ok := proxyMap["password"].(string); ok {
-				node.Password = password
-			}
-			if network, ok := proxyMap["network"].(string); ok {
-				node.Network = network
-			}
-			node.TLS = true // Trojan默认启用TLS
-		}
-		
+		node := parseClashProxy(proxyMap)
 		nodes = append(nodes, node)
 	}
 	
 	// 更新订阅信息
+	bindNodes(sub, nodes)
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	
@@ -276,20 +263,132 @@ func (s *SubscriptionService) parseClashSubscription(sub *model.Subscription, da
 	return nil
 }
 
-// 解析V2ray订阅
+// parseClashProxy 解析Clash代理配置，原始配置保存在RawData中，输出Clash时原样保留未解析的字段
+func parseClashProxy(proxyMap map[string]interface{}) *model.ProxyNode {
+	node := &model.ProxyNode{
+		ID:        uuid.New().String(),
+		Name:      stringField(proxyMap, "name"),
+		Type:      stringField(proxyMap, "type"),
+		Server:    stringField(proxyMap, "server"),
+		Port:      intField(proxyMap, "port"),
+		UDP:       boolField(proxyMap, "udp"),
+		RawData:   proxyMap,
+		RawFormat: model.RawFormatClash,
+		LastCheck: time.Time{},
+	}
+	
+	// 根据类型解析特定字段
+	switch node.Type {
+	case "ss":
+		node.Password = stringField(proxyMap, "password")
+		node.Cipher = stringField(proxyMap, "cipher")
+	case "ssr":
+		node.Password = stringField(proxyMap, "password")
+		node.Cipher = stringField(proxyMap, "cipher")
+	case "vmess", "vless":
+		node.UUID = stringField(proxyMap, "uuid")
+		node.Cipher = stringField(proxyMap, "cipher")
+		node.Network = stringField(proxyMap, "network")
+		node.TLS = boolField(proxyMap, "tls")
+		node.SNI = stringField(proxyMap, "servername")
+	case "trojan":
+		node.Password = stringField(proxyMap, "password")
+		node.Network = stringField(proxyMap, "network")
+		node.SNI = stringField(proxyMap, "sni")
+		node.TLS = true // Trojan默认启用TLS
+	case "hysteria", "hysteria2", "tuic":
+		// 基于QUIC的协议始终使用TLS
+		node.UUID = stringField(proxyMap, "uuid")
+		node.Password = stringField(proxyMap, "password")
+		if node.Type == "hysteria" {
+			node.Password = stringField(proxyMap, "auth-str")
+		}
+		node.SNI = stringField(proxyMap, "sni")
+		node.TLS = true
+	case "socks5", "http":
+		node.Password = stringField(proxyMap, "password")
+		node.TLS = boolField(proxyMap, "tls")
+		node.SNI = stringField(proxyMap, "sni")
+	case "snell":
+		node.Password = stringField(proxyMap, "psk")
+	}
+	
+	if alpn, ok := proxyMap["alpn"].([]interface{}); ok {
+		values := make([]string, 0, len(alpn))
+		for _, value := range alpn {
+			values = append(values, fmt.Sprint(value))
+		}
+		node.ALPN = strings.Join(values, ",")
+	}
+	
+	// 传输层配置
+	if wsOpts, ok := proxyMap["ws-opts"].(map[string]interface{}); ok {
+		node.Path = stringField(wsOpts, "path")
+		if headers, ok := wsOpts["headers"].(map[string]interface{}); ok {
+			node.Host = stringField(headers, "Host")
+		}
+	}
+	if grpcOpts, ok := proxyMap["grpc-opts"].(map[string]interface{}); ok {
+		node.ServiceName = stringField(grpcOpts, "grpc-service-name")
+	}
+	
+	return node
+}
+
+// stringField 读取字符串字段
+func stringField(m map[string]interface{}, key string) string {
+	switch value := m[key].(type) {
+	case string:
+		return value
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// intField 读取整数字段，兼容YAML整数、JSON浮点数与字符串
+func intField(m map[string]interface{}, key string) int {
+	switch value := m[key].(type) {
+	case int:
+		return value
+	case int64:
+		return int(value)
+	case float64:
+		return int(value)
+	case string:
+		var result int
+		fmt.Sscanf(value, "%d", &result)
+		return result
+	}
+	return 0
+}
+
+// boolField 读取布尔字段
+func boolField(m map[string]interface{}, key string) bool {
+	switch value := m[key].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	}
+	return false
+}
+
+// 解析V2ray订阅，内容为Base64编码或明文的分享链接列表
 func (s *SubscriptionService) parseV2raySubscription(sub *model.Subscription, data []byte) error {
-	// Base64解码
-	decoded, err := base64.StdEncoding.DecodeString(string(data))
-	if err != nil {
-		// 尝试使用URLEncoding
-		decoded, err = base64.URLEncoding.DecodeString(string(data))
+	// Base64解码，未编码的链接列表直接使用
+	content := strings.TrimSpace(string(data))
+	if !strings.Contains(content, "://") {
+		decoded, err := decodeBase64(content)
 		if err != nil {
 			return errors.New("无法解码Base64数据")
 		}
+		content = decoded
 	}
 	
 	// 分割每行
-	lines := strings.Split(string(decoded), "\n")
+	lines := strings.Split(content, "\n")
 	nodes := make([]*model.ProxyNode, 0, len(lines))
 	
 	for _, line := range lines {
@@ -298,17 +397,16 @@ func (s *SubscriptionService) parseV2raySubscription(sub *model.Subscription, da
 			continue
 		}
 		
-		// 目前只支持vmess://格式
-		if strings.HasPrefix(line, "vmess://") {
-			node, err := s.parseVmessURL(line)
-			if err != nil {
-				continue
-			}
-			nodes = append(nodes, node)
+		// 转换为Clash.Meta代理配置后按Clash节点解析，跳过不支持的链接
+		proxy, err := parseShareURI(line)
+		if err != nil {
+			continue
 		}
+		nodes = append(nodes, parseClashProxy(proxy))
 	}
 	
 	// 更新订阅信息
+	bindNodes(sub, nodes)
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	
@@ -319,78 +417,13 @@ func (s *SubscriptionService) parseV2raySubscription(sub *model.Subscription, da
 	return nil
 }
 
-// 解析vmess URL
-func (s *SubscriptionService) parseVmessURL(vmessURL string) (*model.ProxyNode, error) {
-	// 移除前缀
-	vmessURL = strings.TrimPrefix(vmessURL, "vmess://")
-	
-	// Base64解码
-	decoded, err := base64.StdEncoding.DecodeString(vmessURL)
-	if err != nil {
-		decoded, err = base64.URLEncoding.DecodeString(vmessURL)
-		if err != nil {
-			return nil, err
-		}
-	}
-	
-	// 解析JSON
-	var vmessConfig map[string]interface{}
-	if err := json.Unmarshal(decoded, &vmessConfig); err != nil {
-		return nil, err
-	}
-	
-	node := &model.ProxyNode{
-		ID:       uuid.New().String(),
-		Type:     "vmess",
-		RawData:  vmessConfig,
-		LastCheck: time.Time{},
-		APIConnectivity: make(map[string]bool),
-	}
-	
-	// 解析基本信息
-	if ps, ok := vmessConfig["ps"].(string); ok {
-		node.Name = ps
-	}
-	
-	if add, ok := vmessConfig["add"].(string); ok {
-		node.Server = add
-	}
-	
-	if port, ok := vmessConfig["port"].(float64); ok {
-		node.Port = int(port)
-	} else if portStr, ok := vmessConfig["port"].(string); ok {
-		var portInt int
-		fmt.Sscanf(portStr, "%d", &portInt)
-		node.Port = portInt
-	}
-	
-	if id, ok := vmessConfig["id"].(string); ok {
-		node.UUID = id
-	}
-	
-	if aid, ok := vmessConfig["aid"].(float64); ok {
-		// 可以保存到RawData中
-		node.RawData["aid"] = int(aid)
-	}
-	
-	if net, ok := vmessConfig["net"].(string); ok {
-		node.Network = net
-	}
-	
-	if tls, ok := vmessConfig["tls"].(string); ok {
-		node.TLS = tls == "tls"
-	}
-	
-	return node, nil
-}
-
 // GetAllNodes 获取所有节点
 func (s *SubscriptionService) GetAllNodes() []*model.ProxyNode {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 	
 	var allNodes []*model.ProxyNode
-	for _, sub := range s.subscriptions {
+	for _, sub := range s.sortedSubscriptions() {
 		allNodes = append(allNodes, sub.Nodes...)
 	}
 	
@@ -403,7 +436,7 @@ func (s *SubscriptionService) GetActiveNodes() []*model.ProxyNode {
 	defer s.mutex.RUnlock()
 	
 	var activeNodes []*model.ProxyNode
-	for _, sub := range s.subscriptions {
+	for _, sub := range s.sortedSubscriptions() {
 		for _, node := range sub.Nodes {
 			if node.Active {
 				activeNodes = append(activeNodes, node)
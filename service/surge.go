@@ -0,0 +1,245 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// surgeGeneral Surge的[General]配置
+const surgeGeneral = `[General]
+loglevel = notify
+bypass-system = true
+skip-proxy = 127.0.0.1,192.168.0.0/16,10.0.0.0/8,172.16.0.0/12,100.64.0.0/10,localhost,*.local,e.crashlytics.com,captive.apple.com,::ffff:0:0:0:0/1,::ffff:128:0:0:0/1
+bypass-tun = 10.0.0.0/8,100.64.0.0/10,127.0.0.0/8,169.254.0.0/16,172.16.0.0/12,192.0.0.0/24,192.0.2.0/24,192.88.99.0/24,192.168.0.0/16,198.18.0.0/15,198.51.100.0/24,203.0.113.0/24,224.0.0.0/4,255.255.255.255/32
+dns-server = system,119.29.29.29,223.5.5.5
+ipv6 = false
+`
+
+// GenerateSurgeConfig 生成Surge配置
+func (g *OutputGenerator) GenerateSurgeConfig(nodes []*model.ProxyNode) (string, error) {
+	client, err := g.buildClientConfig(nodes, surgeProxy)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# ShareSubWeb Subscription\n\n")
+	sb.WriteString(surgeGeneral)
+	sb.WriteString(fmt.Sprintf("proxy-test-url = %s\n\n", client.testURL))
+
+	// 添加代理配置
+	sb.WriteString("[Proxy]\n")
+	sb.WriteString("DIRECT = direct\n")
+	for _, line := range client.lines {
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("\n")
+
+	// 添加代理组配置
+	sb.WriteString("[Proxy Group]\n")
+	for _, group := range client.groups {
+		sb.WriteString(surgeGroup(group, ", ") + "\n")
+	}
+	sb.WriteString("\n")
+
+	// 添加规则配置
+	sb.WriteString("[Rule]\n")
+	for _, rule := range client.rules {
+		sb.WriteString(surgeRule(rule) + "\n")
+	}
+
+	// WireGuard节点的配置位于独立的段落
+	for _, proxy := range client.proxies {
+		if stringField(proxy, "type") == "wireguard" {
+			sb.WriteString("\n" + surgeWireGuardSection(proxy))
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// surgeProxy 将Clash.Meta代理配置转换为Surge的代理行，Surge无法表示的节点返回false
+func surgeProxy(proxy map[string]interface{}) (string, bool) {
+	name := stringField(proxy, "name")
+	server := stringField(proxy, "server")
+	port := strconv.Itoa(intField(proxy, "port"))
+	var options []string
+
+	switch stringField(proxy, "type") {
+	case "ss":
+		options = []string{"ss", server, port,
+			keyValue("encrypt-method", stringField(proxy, "cipher")),
+			keyValue("password", stringField(proxy, "password")),
+		}
+		switch stringField(proxy, "plugin") {
+		case "":
+		case "obfs":
+			opts := mapField(proxy, "plugin-opts")
+			options = append(options,
+				keyValue("obfs", stringOr(stringField(opts, "mode"), "http")),
+				keyValue("obfs-host", stringField(opts, "host")))
+		default:
+			return "", false
+		}
+		options = append(options, keyTrue("udp-relay", boolField(proxy, "udp")))
+	case "vmess":
+		options = []string{"vmess", server, port, keyValue("username", stringField(proxy, "uuid"))}
+		if !setSurgeTransport(&options, proxy) {
+			return "", false
+		}
+		options = append(options, keyTrue("vmess-aead", intField(proxy, "alterId") == 0))
+		if boolField(proxy, "tls") {
+			options = append(options, "tls=true")
+			options = append(options, clientTLS(proxy, "servername")...)
+		}
+	case "trojan":
+		if mapField(proxy, "reality-opts") != nil {
+			return "", false
+		}
+		options = []string{"trojan", server, port, keyValue("password", stringField(proxy, "password"))}
+		if !setSurgeTransport(&options, proxy) {
+			return "", false
+		}
+		options = append(options, clientTLS(proxy, "sni")...)
+	case "http":
+		scheme := "http"
+		if boolField(proxy, "tls") {
+			scheme = "https"
+		}
+		options = append([]string{scheme, server, port}, surgeAuth(proxy)...)
+		if scheme == "https" {
+			options = append(options, clientTLS(proxy, "sni")...)
+		}
+	case "socks5":
+		scheme := "socks5"
+		if boolField(proxy, "tls") {
+			scheme = "socks5-tls"
+		}
+		options = append([]string{scheme, server, port}, surgeAuth(proxy)...)
+		if scheme == "socks5-tls" {
+			options = append(options, clientTLS(proxy, "sni")...)
+		}
+		options = append(options, keyTrue("udp-relay", boolField(proxy, "udp")))
+	case "snell":
+		opts := mapField(proxy, "obfs-opts")
+		options = []string{"snell", server, port,
+			keyValue("psk", stringField(proxy, "psk")),
+			keyValue("version", stringOr(stringField(proxy, "version"), "1")),
+			keyValue("obfs", stringField(opts, "mode")),
+			keyValue("obfs-host", stringField(opts, "host")),
+		}
+	case "tuic":
+		if token := stringField(proxy, "token"); token != "" {
+			options = []string{"tuic", server, port, keyValue("token", token)}
+		} else {
+			options = []string{"tuic-v5", server, port,
+				keyValue("password", stringField(proxy, "password")),
+				keyValue("uuid", stringField(proxy, "uuid")),
+			}
+		}
+		options = append(options, keyValue("alpn", strings.Join(listField(proxy, "alpn"), ",")))
+		options = append(options, clientTLS(proxy, "sni")...)
+	case "hysteria2":
+		// Surge的Hysteria2不支持混淆
+		if stringField(proxy, "obfs") != "" {
+			return "", false
+		}
+		options = []string{"hysteria2", server, port, keyValue("password", stringField(proxy, "password"))}
+		if down := parseMbps(proxy["down"]); down > 0 {
+			options = append(options, keyValue("download-bandwidth", strconv.Itoa(down)))
+		}
+		options = append(options, clientTLS(proxy, "sni")...)
+	case "wireguard":
+		if stringField(proxy, "ip") == "" {
+			return "", false
+		}
+		options = []string{"wireguard", keyValue("section-name", name)}
+	default:
+		// vless、ssr、hysteria等Surge不支持的类型
+		return "", false
+	}
+
+	return name + " = " + joinOptions(", ", options...), true
+}
+
+// setSurgeTransport 设置vmess与trojan的传输方式，Surge只支持tcp与ws
+func setSurgeTransport(options *[]string, proxy map[string]interface{}) bool {
+	switch stringField(proxy, "network") {
+	case "", "tcp":
+	case "ws":
+		opts := mapField(proxy, "ws-opts")
+		*options = append(*options, "ws=true", keyValue("ws-path", stringField(opts, "path")))
+		if host := stringField(mapField(opts, "headers"), "Host"); host != "" {
+			*options = append(*options, fmt.Sprintf("ws-headers=Host:%q", host))
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// surgeAuth 生成HTTP与SOCKS5代理的用户名与密码
+func surgeAuth(proxy map[string]interface{}) []string {
+	username := stringField(proxy, "username")
+	if username == "" {
+		return nil
+	}
+	return []string{username, stringField(proxy, "password")}
+}
+
+// surgeWireGuardSection 生成WireGuard节点引用的[WireGuard]段落
+func surgeWireGuardSection(proxy map[string]interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[WireGuard %s]\n", stringField(proxy, "name")))
+	sb.WriteString(fmt.Sprintf("private-key = %s\n", stringField(proxy, "private-key")))
+	sb.WriteString(fmt.Sprintf("self-ip = %s\n", strings.TrimSuffix(stringField(proxy, "ip"), "/32")))
+	if ipv6 := stringField(proxy, "ipv6"); ipv6 != "" {
+		sb.WriteString(fmt.Sprintf("self-ip-v6 = %s\n", strings.TrimSuffix(ipv6, "/128")))
+	}
+	if mtu := intField(proxy, "mtu"); mtu > 0 {
+		sb.WriteString(fmt.Sprintf("mtu = %d\n", mtu))
+	}
+
+	peer := []string{
+		"public-key = " + stringField(proxy, "public-key"),
+		`allowed-ips = "0.0.0.0/0, ::/0"`,
+		fmt.Sprintf("endpoint = %s:%d", stringField(proxy, "server"), intField(proxy, "port")),
+	}
+	if psk := stringField(proxy, "pre-shared-key"); psk != "" {
+		peer = append(peer, "preshared-key = "+psk)
+	}
+	if reserved := intListField(proxy, "reserved"); len(reserved) > 0 {
+		values := make([]string, len(reserved))
+		for i, value := range reserved {
+			values[i] = strconv.Itoa(value)
+		}
+		peer = append(peer, "client-id = "+strings.Join(values, "/"))
+	}
+	sb.WriteString(fmt.Sprintf("peer = (%s)\n", strings.Join(peer, ", ")))
+	return sb.String()
+}
+
+// surgeGroup 生成Surge与Loon的代理组行，sep为参数分隔符
+func surgeGroup(group ClashProxyGroup, sep string) string {
+	options := append([]string{group.Type}, group.Proxies...)
+	if group.Type != "select" {
+		options = append(options,
+			keyValue("url", group.URL),
+			keyValue("interval", strconv.Itoa(group.Interval)))
+	}
+	if group.Type == "url-test" && group.Tolerance > 0 {
+		options = append(options, keyValue("tolerance", strconv.Itoa(group.Tolerance)))
+	}
+	return group.Name + " = " + joinOptions(sep, options...)
+}
+
+// surgeRule 生成Surge与Loon的规则，兜底规则为FINAL
+func surgeRule(rule clientRule) string {
+	if rule.Type == "MATCH" {
+		return "FINAL," + rule.Policy
+	}
+	return strings.Join(append([]string{rule.Type, rule.Value, rule.Policy}, rule.Options...), ",")
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nariahlamb/sharesubweb/config"
+	"github.com/nariahlamb/sharesubweb/model"
+)
+
+// TestShareURIRoundTrip 每种协议生成的分享链接经订阅解析后再次生成，结果与原链接一致且节点信息无丢失
+func TestShareURIRoundTrip(t *testing.T) {
+	generator := NewOutputGenerator(&config.Config{})
+	for _, input := range clashTestdata(t) {
+		t.Run(input, func(t *testing.T) {
+			_, nodes := parseClashTestdata(t, input)
+			content, err := generator.GenerateBase64Config(nodes)
+			if err != nil {
+				t.Fatalf("生成分享链接失败: %v", err)
+			}
+
+			sub := &model.Subscription{ID: "v2ray", Name: "v2ray", Type: "v2ray"}
+			if err := NewSubscriptionService(&config.Config{}).parseV2raySubscription(sub, []byte(content)); err != nil {
+				t.Fatalf("解析订阅失败: %v", err)
+			}
+			links, _ := base64.StdEncoding.DecodeString(content)
+			if count := len(strings.Fields(string(links))); len(sub.Nodes) != count {
+				t.Fatalf("解析出 %d 个节点，期望 %d", len(sub.Nodes), count)
+			}
+
+			original := make(map[string]*model.ProxyNode, len(nodes))
+			for _, node := range nodes {
+				original[node.Name] = node
+			}
+			for _, node := range sub.Nodes {
+				node.Active = true
+				want := original[node.Name]
+				if want == nil {
+					t.Errorf("解析出未知节点: %q", node.Name)
+					continue
+				}
+				// 分享链接不区分未设置与tcp传输
+				got := [...]interface{}{node.Type, node.Server, node.Port, node.UUID, node.Password, node.Cipher, stringOr(node.Network, "tcp"), node.TLS}
+				expected := [...]interface{}{want.Type, want.Server, want.Port, want.UUID, want.Password, want.Cipher, stringOr(want.Network, "tcp"), want.TLS}
+				if got != expected {
+					t.Errorf("节点 %s 解析结果为 %v，期望 %v", node.Name, got, expected)
+				}
+			}
+
+			again, err := generator.GenerateBase64Config(sub.Nodes)
+			if err != nil {
+				t.Fatalf("再次生成分享链接失败: %v", err)
+			}
+			if again != content {
+				decoded, _ := base64.StdEncoding.DecodeString(again)
+				t.Errorf("再次生成的分享链接不一致\n输出:\n%s\n期望:\n%s", decoded, links)
+			}
+		})
+	}
+}
+
+// TestParseShareURI 按v2rayN与SIP002规范解析分享链接
+func TestParseShareURI(t *testing.T) {
+	tests := []struct {
+		link string
+		want map[string]interface{}
+	}{
+		{
+			link: "ss://YWVzLTEyOC1nY206dGVzdA@192.168.100.1:8888#Example1",
+			want: map[string]interface{}{
+				"name": "Example1", "type": "ss", "server": "192.168.100.1", "port": 8888,
+				"cipher": "aes-128-gcm", "password": "test",
+			},
+		},
+		{
+			link: "ss://cmM0LW1kNTpwYXNzd2Q@192.168.100.1:8888/?plugin=obfs-local%3Bobfs%3Dhttp#Example2",
+			want: map[string]interface{}{
+				"name": "Example2", "type": "ss", "server": "192.168.100.1", "port": 8888,
+				"cipher": "rc4-md5", "password": "passwd",
+				"plugin": "obfs", "plugin-opts": map[string]interface{}{"mode": "http"},
+			},
+		},
+		{
+			link: "ss://2022-blake3-aes-256-gcm:YctPZ6U7xPPcU%2Bgp3u%2B0tx%2FtRizJN9K8y%2BuKlW2qjlI%3D@192.168.100.1:8888#Example3",
+			want: map[string]interface{}{
+				"name": "Example3", "type": "ss", "server": "192.168.100.1", "port": 8888,
+				"cipher": "2022-blake3-aes-256-gcm", "password": "YctPZ6U7xPPcU+gp3u+0tx/tRizJN9K8y+uKlW2qjlI=",
+			},
+		},
+		{
+			// 旧格式：整体Base64编码
+			link: "ss://" + base64.StdEncoding.EncodeToString([]byte("aes-256-gcm:p@ss@[2001:db8::1]:8388")) + "#%E6%97%A7%E6%A0%BC%E5%BC%8F",
+			want: map[string]interface{}{
+				"name": "旧格式", "type": "ss", "server": "2001:db8::1", "port": 8388,
+				"cipher": "aes-256-gcm", "password": "p@ss",
+			},
+		},
+		{
+			// v2rayN早期版本的端口与alterId为数字
+			link: "vmess://" + base64.StdEncoding.EncodeToString([]byte(`{"v":"2","ps":"香港 01","add":"hk.example.com","port":443,"id":"b831381d-6324-4d53-ad4f-8cda48b30811","aid":0,"net":"ws","type":"none","host":"cdn.example.com","path":"/ws","tls":"tls","sni":"cdn.example.com"}`)),
+			want: map[string]interface{}{
+				"name": "香港 01", "type": "vmess", "server": "hk.example.com", "port": 443,
+				"uuid": "b831381d-6324-4d53-ad4f-8cda48b30811", "alterId": 0, "cipher": "auto",
+				"network": "ws", "ws-opts": map[string]interface{}{
+					"path": "/ws", "headers": map[string]interface{}{"Host": "cdn.example.com"},
+				},
+				"tls": true, "servername": "cdn.example.com",
+			},
+		},
+		{
+			link: "trojan://p%40ss@192.0.2.1:443?security=tls&type=ws&host=cdn.example.com&path=%2Ftrojan&sni=cdn.example.com#Hong%20Kong%2001",
+			want: map[string]interface{}{
+				"name": "Hong Kong 01", "type": "trojan", "server": "192.0.2.1", "port": 443, "password": "p@ss",
+				"network": "ws", "ws-opts": map[string]interface{}{
+					"path": "/trojan", "headers": map[string]interface{}{"Host": "cdn.example.com"},
+				},
+				"sni": "cdn.example.com",
+			},
+		},
+		{
+			// 省略security参数的trojan链接同样读取TLS参数
+			link: "trojan://pw@example.com:443?sni=cdn.example.org&allowInsecure=1#a",
+			want: map[string]interface{}{
+				"name": "a", "type": "trojan", "server": "example.com", "port": 443, "password": "pw",
+				"sni": "cdn.example.org", "skip-cert-verify": true,
+			},
+		},
+		{
+			// 旧版链接使用peer表示SNI
+			link: "trojan://pw@example.com:443?peer=cdn.example.org&alpn=h2,http/1.1#b",
+			want: map[string]interface{}{
+				"name": "b", "type": "trojan", "server": "example.com", "port": 443, "password": "pw",
+				"sni": "cdn.example.org", "alpn": []interface{}{"h2", "http/1.1"},
+			},
+		},
+		{
+			link: "vless://5fe0b1b3-0a1b-4c6b-8a1e-3a9f0f5e0c21@vless.example.com:443?encryption=none&type=tcp&headerType=http&host=a.com,b.com&path=%2Fv#tcp-http",
+			want: map[string]interface{}{
+				"name": "tcp-http", "type": "vless", "server": "vless.example.com", "port": 443,
+				"uuid":    "5fe0b1b3-0a1b-4c6b-8a1e-3a9f0f5e0c21",
+				"network": "http", "http-opts": map[string]interface{}{
+					"path":    []interface{}{"/v"},
+					"headers": map[string]interface{}{"Host": []interface{}{"a.com", "b.com"}},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := parseShareURI(test.link)
+		if err != nil {
+			t.Errorf("解析 %s 失败: %v", test.link, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("解析 %s\n结果: %#v\n期望: %#v", test.link, got, test.want)
+		}
+	}
+
+	// 无效的链接返回错误而不是panic
+	for _, link := range []string{
+		"ss://YWJj",
+		"ss://YWJjOmRlZg",
+		"ss://YWVzLTEyOC1nY206dGVzdA@192.168.100.1:",
+		"ss://YWVzLTEyOC1nY206dGVzdA@192.168.100.1",
+		"ss://!!!invalid-base64!!!",
+		"ss://",
+		"vmess://!!!",
+		"trojan://secret@example.com:#name",
+	} {
+		if proxy, err := parseShareURI(link); err == nil {
+			t.Errorf("解析 %s 应失败，得到 %#v", link, proxy)
+		}
+	}
+}
+
+// TestShareURIName 名称中的空格编码为%20而不是+
+func TestShareURIName(t *testing.T) {
+	proxy := map[string]interface{}{"type": "trojan", "server": "192.0.2.1", "port": 443, "password": "secret"}
+	uri, ok := shareURI(proxy, "Hong Kong 01")
+	if !ok {
+		t.Fatal("生成分享链接失败")
+	}
+	if !strings.HasSuffix(uri, "#Hong%20Kong%2001") {
+		t.Errorf("分享链接为 %s，名称编码不正确", uri)
+	}
+}